@@ -1,8 +1,11 @@
 package api
 
 import (
+	"net"
+	"net/http"
 	"net/url"
 	gopath "path"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 )
@@ -12,6 +15,21 @@ const (
 	g5kAPIversion  string = "3.0"
 )
 
+// sharedTransport is the HTTP transport of all the clients, its keep-alive connections to the API are reused by the successive requests
+var sharedTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   10,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: 1 * time.Second,
+}
+
 // Client is a client to the Grid'5000 REST API
 type Client struct {
 	caller  *resty.Client
@@ -21,6 +39,7 @@ type Client struct {
 // NewClient returns a new configured Grid'5000 API client
 func NewClient(username, password, site string) *Client {
 	caller := resty.New().
+		SetTransport(sharedTransport).
 		SetHeader("Accept", "application/json").
 		SetBasicAuth(username, password)
 
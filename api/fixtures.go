@@ -0,0 +1,148 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// fixtureUsername replaces the username of the account in the recorded fixtures
+const fixtureUsername string = "g5kuser"
+
+// newUsernameScrubber returns a regexp matching the given username as a whole word, nil if the username is empty
+func newUsernameScrubber(username string) *regexp.Regexp {
+	if username == "" {
+		return nil
+	}
+	return regexp.MustCompile(`\b` + regexp.QuoteMeta(username) + `\b`)
+}
+
+// scrubExchange returns a copy of the exchange with the username replaced
+func scrubExchange(exchange *Exchange, scrubber *regexp.Regexp) *Exchange {
+	scrubbed := *exchange
+
+	if u, err := url.Parse(scrubber.ReplaceAllString(exchange.URL.String(), fixtureUsername)); err == nil {
+		scrubbed.URL = u
+	}
+
+	scrubbed.RequestHeaders = scrubHeaders(exchange.RequestHeaders, scrubber)
+	scrubbed.ResponseHeaders = scrubHeaders(exchange.ResponseHeaders, scrubber)
+	scrubbed.RequestBody = scrubber.ReplaceAllString(exchange.RequestBody, fixtureUsername)
+	scrubbed.ResponseBody = scrubber.ReplaceAllString(exchange.ResponseBody, fixtureUsername)
+	scrubbed.Error = scrubber.ReplaceAllString(exchange.Error, fixtureUsername)
+
+	return &scrubbed
+}
+
+// scrubHeaders returns a copy of the headers with the username replaced
+func scrubHeaders(headers http.Header, scrubber *regexp.Regexp) http.Header {
+	scrubbed := http.Header{}
+	for name, values := range headers {
+		for _, value := range values {
+			scrubbed.Add(name, scrubber.ReplaceAllString(value, fixtureUsername))
+		}
+	}
+	return scrubbed
+}
+
+// fixtureKey returns the key identifying the requests served by the same fixtures (method, path and query)
+func fixtureKey(method string, u *url.URL) string {
+	return fmt.Sprintf("%s %s?%s", method, u.Path, u.Query().Encode())
+}
+
+// replayTransport is a HTTP transport serving the responses of recorded fixtures instead of sending the requests
+type replayTransport struct {
+	entries  []HAREntry
+	served   []bool
+	scrubber *regexp.Regexp
+	mutex    sync.Mutex
+}
+
+// RoundTrip returns the response of the next fixture matching the request
+// The fixtures matching the same request are served in their recorded order, the last one being served again when they are all consumed
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		ioutil.ReadAll(req.Body)
+		req.Body.Close()
+	}
+
+	requestURL := req.URL
+	if t.scrubber != nil {
+		u, err := url.Parse(t.scrubber.ReplaceAllString(req.URL.String(), fixtureUsername))
+		if err != nil {
+			return nil, err
+		}
+		requestURL = u
+	}
+	key := fixtureKey(req.Method, requestURL)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	match := -1
+	for i, entry := range t.entries {
+		entryURL, err := url.Parse(entry.Request.URL)
+		if err != nil || fixtureKey(entry.Request.Method, entryURL) != key {
+			continue
+		}
+
+		match = i
+		if !t.served[i] {
+			break
+		}
+	}
+
+	if match == -1 {
+		return nil, fmt.Errorf("No fixture matches the request '%s'", key)
+	}
+	t.served[match] = true
+
+	entry := t.entries[match]
+	return &http.Response{
+		StatusCode:    entry.Response.Status,
+		Status:        fmt.Sprintf("%d %s", entry.Response.Status, http.StatusText(entry.Response.Status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        harToHeaders(entry.Response.Headers),
+		Body:          ioutil.NopCloser(strings.NewReader(entry.Response.Content.Text)),
+		ContentLength: int64(len(entry.Response.Content.Text)),
+		Request:       req,
+	}, nil
+}
+
+// EnableRecording append every exchange with the API to the given fixture file (in HAR format)
+// The credentials are removed and the given username is replaced, so the fixtures can be shared
+func (c *Client) EnableRecording(fixturePath string, username string) {
+	transport := c.caller.GetClient().Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	c.caller.SetTransport(&tracingTransport{
+		transport: transport,
+		harPath:   fixturePath,
+		scrubber:  newUsernameScrubber(username),
+	})
+}
+
+// EnableReplay serve the responses of the given fixture file (in HAR format) instead of sending the requests to the API
+// The given username is replaced in the requests to match the recorded fixtures
+func (c *Client) EnableReplay(fixturePath string, username string) error {
+	har, err := LoadHAR(fixturePath)
+	if err != nil {
+		return fmt.Errorf("Failed to load the fixtures: %s", err)
+	}
+
+	c.caller.SetTransport(&replayTransport{
+		entries:  har.Log.Entries,
+		served:   make([]bool, len(har.Log.Entries)),
+		scrubber: newUsernameScrubber(username),
+	})
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"testing"
+)
+
+// newReplayClient returns a client of the rennes site serving the responses of the given fixture file
+func newReplayClient(t *testing.T, fixture string, username string) *Client {
+	t.Helper()
+
+	client := NewClient(username, "password", "rennes")
+	if err := client.EnableReplay(fixture, username); err != nil {
+		t.Fatalf("EnableReplay(%q) returned an error: %s", fixture, err)
+	}
+
+	return client
+}
+
+func TestEnableReplayMissingFixtures(t *testing.T) {
+	client := NewClient(fixtureUsername, "password", "rennes")
+	if err := client.EnableReplay("testdata/missing.har", fixtureUsername); err == nil {
+		t.Fatalf("EnableReplay() of a missing fixture file returned no error")
+	}
+}
+
+func TestReplayUnknownRequest(t *testing.T) {
+	client := newReplayClient(t, "testdata/jobs.har", fixtureUsername)
+
+	if _, err := client.GetJob(42); err == nil {
+		t.Fatalf("GetJob() of a request without fixture returned no error")
+	}
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+)
+
+// HAR represents an archive of HTTP exchanges, in a subset of the HAR 1.2 format
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog stores the exchanges of an archive
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator stores the application that created the archive
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry stores a request and its response
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARRequest stores the attributes of a request
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse stores the attributes of a response
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue stores a header or a query parameter
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData stores the body of a request
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent stores the body of a response
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// NewHAR returns a new empty archive
+func NewHAR() *HAR {
+	return &HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{Name: "docker-machine-driver-g5k", Version: "1"},
+			Entries: []HAREntry{},
+		},
+	}
+}
+
+// LoadHAR load an archive from the given file
+func LoadHAR(path string) (*HAR, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	har := NewHAR()
+	if err := json.Unmarshal(data, har); err != nil {
+		return nil, err
+	}
+
+	return har, nil
+}
+
+// LoadOrCreateHAR load an archive from the given file, or returns an empty archive if the file does not exist
+func LoadOrCreateHAR(path string) (*HAR, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return NewHAR(), nil
+	}
+
+	return LoadHAR(path)
+}
+
+// Save write the archive to the given file
+func (h *HAR) Save(path string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// headersToHAR convert HTTP headers to HAR name/value pairs (sorted by name)
+func headersToHAR(headers http.Header) []HARNameValue {
+	pairs := []HARNameValue{}
+	for name, values := range headers {
+		for _, value := range values {
+			pairs = append(pairs, HARNameValue{Name: name, Value: value})
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+// queryToHAR convert the query of an URL to HAR name/value pairs (sorted by name)
+func queryToHAR(query url.Values) []HARNameValue {
+	pairs := []HARNameValue{}
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, HARNameValue{Name: name, Value: value})
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+// harToHeaders convert HAR name/value pairs to HTTP headers
+func harToHeaders(pairs []HARNameValue) http.Header {
+	headers := http.Header{}
+	for _, pair := range pairs {
+		headers.Add(pair.Name, pair.Value)
+	}
+	return headers
+}
+
+// newHAREntry returns a new archive entry for the given exchange
+func newHAREntry(exchange *Exchange) HAREntry {
+	entry := HAREntry{
+		StartedDateTime: exchange.StartedAt.Format(time.RFC3339Nano),
+		Time:            float64(exchange.Duration) / float64(time.Millisecond),
+		Request: HARRequest{
+			Method:      exchange.Method,
+			URL:         exchange.URL.String(),
+			HTTPVersion: "HTTP/1.1",
+			Headers:     headersToHAR(exchange.RequestHeaders),
+			QueryString: queryToHAR(exchange.URL.Query()),
+			HeadersSize: -1,
+			BodySize:    len(exchange.RequestBody),
+		},
+		Response: HARResponse{
+			Status:      exchange.StatusCode,
+			StatusText:  http.StatusText(exchange.StatusCode),
+			HTTPVersion: "HTTP/1.1",
+			Headers:     headersToHAR(exchange.ResponseHeaders),
+			Content: HARContent{
+				Size:     len(exchange.ResponseBody),
+				MimeType: exchange.ResponseHeaders.Get("Content-Type"),
+				Text:     exchange.ResponseBody,
+			},
+			HeadersSize: -1,
+			BodySize:    len(exchange.ResponseBody),
+		},
+		Comment: exchange.Error,
+	}
+
+	if exchange.RequestBody != "" {
+		entry.Request.PostData = &HARPostData{MimeType: exchange.RequestHeaders.Get("Content-Type"), Text: exchange.RequestBody}
+	}
+
+	return entry
+}
@@ -3,26 +3,47 @@ package api
 import (
 	"fmt"
 	"net/url"
+	"strings"
 )
 
 // JobRequest represents a new job submission
 type JobRequest struct {
+	Name        string   `json:"name,omitempty"`
+	Project     string   `json:"project,omitempty"`
 	Resources   string   `json:"resources"`
 	Command     string   `json:"command"`
 	Properties  string   `json:"properties,omitempty"`
 	Reservation string   `json:"reservation,omitempty"`
 	Types       []string `json:"types"`
 	Queue       string   `json:"queue"`
+	Directory   string   `json:"directory,omitempty"`
+	Stdout      string   `json:"stdout,omitempty"`
+	Stderr      string   `json:"stderr,omitempty"`
+	Checkpoint  int      `json:"checkpoint,omitempty"` // delay (in seconds) before the end of the walltime when the signal is sent to the job
+	Signal      int      `json:"signal,omitempty"`     // signal sent for the checkpoint (SIGUSR2 by default)
+}
+
+// JobEvent represents an event emitted by OAR during the lifetime of a job
+type JobEvent struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	CreatedAt   int    `json:"created_at"`
 }
 
 // Job represents an existing job
 type Job struct {
-	UID       int      `json:"uid"`
-	State     string   `json:"state"`
-	Timelife  int      `json:"walltime"`
-	Types     []string `json:"types"`
-	StartTime int      `json:"started_at"`
-	Nodes     []string `json:"assigned_nodes"`
+	UID         int        `json:"uid"`
+	Name        string     `json:"name"`
+	Project     string     `json:"project"`
+	User        string     `json:"user"`
+	Queue       string     `json:"queue"`
+	State       string     `json:"state"`
+	Timelife    int        `json:"walltime"`
+	Types       []string   `json:"types"`
+	StartTime   int        `json:"started_at"`
+	ScheduledAt int        `json:"scheduled_at"`
+	Nodes       []string   `json:"assigned_nodes"`
+	Events      []JobEvent `json:"events"`
 }
 
 // SubmitJob submit a new job on g5k api and return the job id
@@ -77,6 +98,61 @@ func (c *Client) GetJob(jobID int) (*Job, error) {
 	return job, nil
 }
 
+// jobCollection represents the response of the jobs listing
+type jobCollection struct {
+	Items []Job `json:"items"`
+}
+
+// GetJobs returns the jobs of the user in the given states (all the jobs of the user if no state is given)
+func (c *Client) GetJobs(user string, states []string) ([]Job, error) {
+	params := url.Values{"user": []string{user}}
+	if len(states) > 0 {
+		params.Set("state", strings.Join(states, ","))
+	}
+
+	// send request
+	req, err := c.caller.R().
+		SetResult(&jobCollection{}).
+		Get(c.getEndpoint("jobs", "/", params))
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving the jobs: '%s'", err)
+	}
+
+	// check HTTP error code (expected: 200 OK)
+	if req.StatusCode() != 200 {
+		return nil, fmt.Errorf("The server returned an error (code: %d) while fetching the jobs: '%s'", req.StatusCode(), req.Status())
+	}
+
+	// unmarshal result
+	jobs, ok := req.Result().(*jobCollection)
+	if !ok {
+		return nil, fmt.Errorf("Error in the response of the jobs (unexpected type)")
+	}
+
+	return jobs.Items, nil
+}
+
+// ExtendJob request an extension of the walltime of the job (ex: "+1:00:00"), OAR applies it when the resources are available
+func (c *Client) ExtendJob(jobID int, extension string) error {
+	// send walltime change request
+	req, err := c.caller.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(map[string]string{"walltime": extension}).
+		Put(c.getEndpoint("jobs", fmt.Sprintf("/%v", jobID), url.Values{}))
+
+	if err != nil {
+		return fmt.Errorf("Error while sending the walltime change request: '%s'", err)
+	}
+
+	// check HTTP error code (expected: 202 Accepted)
+	if req.StatusCode() != 200 && req.StatusCode() != 202 {
+		return fmt.Errorf("The server returned an error (code: %d) after sending the walltime change request: '%s'", req.StatusCode(), req.Status())
+	}
+
+	return nil
+}
+
 // KillJob ask for deletion of a job
 func (c *Client) KillJob(jobID int) error {
 	// send delete request
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubmitJob(t *testing.T) {
+	client := newReplayClient(t, "testdata/jobs.har", fixtureUsername)
+
+	jobID, err := client.SubmitJob(JobRequest{
+		Name:      "docker-machine-g5k_1a2b3c4d_test-node",
+		Resources: "nodes=1,walltime=1:00",
+		Command:   "sleep 365d",
+		Types:     []string{"deploy"},
+		Queue:     "default",
+	})
+	if err != nil {
+		t.Fatalf("SubmitJob() returned an error: %s", err)
+	}
+	if jobID != 1234567 {
+		t.Errorf("SubmitJob() = %d, want 1234567", jobID)
+	}
+}
+
+func TestGetJob(t *testing.T) {
+	client := newReplayClient(t, "testdata/jobs.har", fixtureUsername)
+
+	// the fixtures of the same request are served in their recorded order
+	for _, want := range []struct {
+		state string
+		nodes []string
+	}{
+		{"waiting", []string{}},
+		{"running", []string{"paravance-1.rennes.grid5000.fr"}},
+		{"running", []string{"paravance-1.rennes.grid5000.fr"}},
+	} {
+		job, err := client.GetJob(1234567)
+		if err != nil {
+			t.Fatalf("GetJob() returned an error: %s", err)
+		}
+
+		if job.UID != 1234567 || job.Name != "docker-machine-g5k_1a2b3c4d_test-node" || job.User != fixtureUsername {
+			t.Errorf("GetJob() = {uid: %d, name: %q, user: %q}, want {uid: 1234567, name: %q, user: %q}", job.UID, job.Name, job.User, "docker-machine-g5k_1a2b3c4d_test-node", fixtureUsername)
+		}
+		if job.State != want.state || !reflect.DeepEqual(job.Nodes, want.nodes) {
+			t.Errorf("GetJob() = {state: %q, nodes: %v}, want {state: %q, nodes: %v}", job.State, job.Nodes, want.state, want.nodes)
+		}
+	}
+}
+
+func TestGetJobNotFound(t *testing.T) {
+	client := newReplayClient(t, "testdata/jobs.har", fixtureUsername)
+
+	if _, err := client.GetJob(7654321); err == nil {
+		t.Fatalf("GetJob() of an unknown job returned no error")
+	}
+}
+
+func TestGetJobsScrubsUsername(t *testing.T) {
+	// the username of the request is replaced to match the sanitized fixtures
+	client := newReplayClient(t, "testdata/jobs.har", "alice")
+
+	jobs, err := client.GetJobs("alice", []string{"running", "waiting"})
+	if err != nil {
+		t.Fatalf("GetJobs() returned an error: %s", err)
+	}
+	if len(jobs) != 2 || jobs[0].UID != 1234567 || jobs[1].UID != 1234581 {
+		t.Errorf("GetJobs() = %+v, want the jobs 1234567 and 1234581", jobs)
+	}
+}
+
+func TestGetJobsAssignedNodes(t *testing.T) {
+	client := newReplayClient(t, "testdata/jobs.har", fixtureUsername)
+
+	// the listing returns the complete jobs, so the nodes of the running jobs are known without fetching each job
+	jobs, err := client.GetJobs(fixtureUsername, []string{"running", "waiting"})
+	if err != nil {
+		t.Fatalf("GetJobs() returned an error: %s", err)
+	}
+
+	want := map[int][]string{
+		1234567: {"paravance-1.rennes.grid5000.fr"},
+		1234581: {},
+	}
+	if len(jobs) != len(want) {
+		t.Fatalf("GetJobs() returned %d jobs, want %d", len(jobs), len(want))
+	}
+	for _, job := range jobs {
+		if !reflect.DeepEqual(job.Nodes, want[job.UID]) {
+			t.Errorf("GetJobs() job %d (state: %q) has the nodes %v, want %v", job.UID, job.State, job.Nodes, want[job.UID])
+		}
+	}
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"fmt"
 	"net/url"
+	"regexp"
+	"time"
 )
 
 // PowerOperation stores the attributes for a Power operation
@@ -13,10 +15,26 @@ type PowerOperation struct {
 }
 
 // RebootOperation stores the attributes for a Reboot operation
+// The kind can be 'simple', 'set_pxe' (needs a PXE profile), 'recorded_env' (needs an environment) or 'deploy_env'
 type RebootOperation struct {
-	Kind  string   `json:"kind"`
-	Nodes []string `json:"nodes"`
-	Level string   `json:"level,omitempty"`
+	Kind        string                `json:"kind"`
+	Nodes       []string              `json:"nodes"`
+	Level       string                `json:"level,omitempty"`
+	Environment *OperationEnvironment `json:"environment,omitempty"`
+	PXE         *OperationPXE         `json:"pxe,omitempty"`
+}
+
+// OperationEnvironment stores the attributes of the environment to boot for a 'recorded_env' reboot operation
+type OperationEnvironment struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	User    string `json:"user,omitempty"`
+	Version int    `json:"version,omitempty"`
+}
+
+// OperationPXE stores the attributes of the PXE profile to set for a 'set_pxe' reboot operation
+type OperationPXE struct {
+	Profile string `json:"profile"`
 }
 
 // OperationResponse stores the attributes of the response of the submission of an operation
@@ -40,6 +58,24 @@ type OperationStates map[string]struct {
 	Out   string `json:"out,omitempty"`
 }
 
+// powerStatusRegexp matches the BMC power status in the out attribute of the state of a power status workflow
+var powerStatusRegexp = regexp.MustCompile(`-bmc: (on|off)$`)
+
+// PowerStatus returns the BMC power status (on/off) of the node from the states of a power status workflow
+func (s OperationStates) PowerStatus(node string) (string, error) {
+	state, ok := s[node]
+	if !ok {
+		return "", fmt.Errorf("Failed to retrieve the workflow state of the power status operation")
+	}
+
+	matches := powerStatusRegexp.FindStringSubmatch(state.Out)
+	if matches == nil {
+		return "", fmt.Errorf("The BMC status in the workflow state is invalid: %s", state.Out)
+	}
+
+	return matches[1], nil
+}
+
 // DeploymentRequest represents a new deployment submission
 type DeploymentRequest struct {
 	Nodes       []string `json:"nodes"`
@@ -47,6 +83,17 @@ type DeploymentRequest struct {
 	Key         string   `json:"key"`
 }
 
+// Environment represents an environment registered in Kadeploy3
+type Environment struct {
+	Name        string `json:"name"`
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	Alias       string `json:"alias"`
+	Arch        string `json:"arch"`
+	User        string `json:"user"`
+	Visibility  string `json:"visibility"`
+}
+
 // Deployment represents the response of a new deployment request
 type DeploymentResponse struct {
 	UID string `json:"uid"`
@@ -204,3 +251,83 @@ func (c *Client) GetOperationStates(operation string, wid string) (*OperationSta
 
 	return states, nil
 }
+
+// GetEnvironments fetch and return the last version of the environments registered by the given user in the Kadeploy3 API
+func (c *Client) GetEnvironments(user string) ([]Environment, error) {
+	// get environments from kadeploy3 API
+	req, err := c.caller.R().
+		SetResult(&[]Environment{}).
+		Get(c.getEndpoint("internal/kadeployapi", "/environments", url.Values{"username": []string{user}, "last": []string{"true"}}))
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving the environments: '%s'", err)
+	}
+
+	// check HTTP error code (expected: 200 OK)
+	if req.StatusCode() != 200 {
+		return nil, fmt.Errorf("The server returned an error (code: %d) while fetching the environments: '%s'", req.StatusCode(), req.Status())
+	}
+
+	// unmarshal result
+	environments, ok := req.Result().(*[]Environment)
+	if !ok {
+		return nil, fmt.Errorf("Error in the response of the environments (unexpected type)")
+	}
+
+	return *environments, nil
+}
+
+// WorkflowError is returned when the workflow of an operation failed or is not done in time for a node
+type WorkflowError struct {
+	Operation string
+	WID       string
+	Node      string
+	TimedOut  bool
+}
+
+// Error returns the description of the workflow error
+func (e *WorkflowError) Error() string {
+	if e.TimedOut {
+		return fmt.Sprintf("Timeout while waiting for the workflow of '%s' operation for the '%s' node (workflow id: '%s')", e.Operation, e.Node, e.WID)
+	}
+	return fmt.Sprintf("Workflow for '%s' operation failed for the '%s' node (workflow id: '%s')", e.Operation, e.Node, e.WID)
+}
+
+// WaitForWorkflow poll the workflow of the operation until it is done for the node, a WorkflowError is returned if it failed or the timeout expired
+// A zero timeout waits without limit. The optional processing function is called at each poll while the workflow is processing the node
+func (c *Client) WaitForWorkflow(operation string, wid string, node string, interval time.Duration, timeout time.Duration, processing func()) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		workflow, err := c.GetOperationWorkflow(operation, wid)
+		if err != nil {
+			return err
+		}
+
+		for _, n := range workflow.Nodes["ok"] {
+			if n == node {
+				return nil
+			}
+		}
+
+		for _, n := range workflow.Nodes["ko"] {
+			if n == node {
+				return &WorkflowError{Operation: operation, WID: wid, Node: node}
+			}
+		}
+
+		if processing != nil {
+			for _, n := range workflow.Nodes["processing"] {
+				if n == node {
+					processing()
+				}
+			}
+		}
+
+		if timeout > 0 && time.Now().Add(interval).After(deadline) {
+			return &WorkflowError{Operation: operation, WID: wid, Node: node, TimedOut: true}
+		}
+
+		// wait before making another API call
+		time.Sleep(interval)
+	}
+}
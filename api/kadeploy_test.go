@@ -0,0 +1,95 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+const (
+	testDeploymentWID string = "D-0a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d"
+	testRebootWID     string = "R-9f8e7d6c-5b4a-3928-1706-f5e4d3c2b1a0"
+	testPowerWID      string = "P-1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d"
+	testNode          string = "paravance-1.rennes.grid5000.fr"
+)
+
+func TestSubmitDeployment(t *testing.T) {
+	client := newReplayClient(t, "testdata/deployments.har", fixtureUsername)
+
+	deployment, err := client.SubmitDeployment(DeploymentRequest{
+		Nodes:       []string{testNode},
+		Environment: "debian12-min",
+		Key:         "ssh-ed25519 AAAA g5kuser@laptop",
+	})
+	if err != nil {
+		t.Fatalf("SubmitDeployment() returned an error: %s", err)
+	}
+	if deployment.UID != testDeploymentWID {
+		t.Errorf("SubmitDeployment() = %q, want %q", deployment.UID, testDeploymentWID)
+	}
+}
+
+func TestGetOperationWorkflow(t *testing.T) {
+	client := newReplayClient(t, "testdata/deployments.har", fixtureUsername)
+
+	for _, want := range []struct {
+		done   bool
+		status string
+	}{
+		{false, "processing"},
+		{true, "ok"},
+	} {
+		workflow, err := client.GetOperationWorkflow("deployment", testDeploymentWID)
+		if err != nil {
+			t.Fatalf("GetOperationWorkflow() returned an error: %s", err)
+		}
+
+		if workflow.Done != want.done || len(workflow.Nodes[want.status]) != 1 || workflow.Nodes[want.status][0] != testNode {
+			t.Errorf("GetOperationWorkflow() = %+v, want done: %t with the node %s", workflow, want.done, want.status)
+		}
+	}
+}
+
+func TestWaitForWorkflow(t *testing.T) {
+	tests := []struct {
+		name       string
+		operation  string
+		wid        string
+		timeout    time.Duration
+		wantErr    bool
+		timedOut   bool
+		processing int
+	}{
+		{"deployment done", "deployment", testDeploymentWID, 20 * time.Millisecond, false, false, 1},
+		{"deployment without timeout", "deployment", testDeploymentWID, 0, false, false, 1},
+		{"reboot failed", "reboot", testRebootWID, 20 * time.Millisecond, true, false, 0},
+		{"power timeout", "power", testPowerWID, 20 * time.Millisecond, true, true, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newReplayClient(t, "testdata/deployments.har", fixtureUsername)
+
+			processing := 0
+			err := client.WaitForWorkflow(tt.operation, tt.wid, testNode, time.Millisecond, tt.timeout, func() { processing++ })
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("WaitForWorkflow() returned an error: %s", err)
+				}
+			} else {
+				workflowErr, ok := err.(*WorkflowError)
+				if !ok {
+					t.Fatalf("WaitForWorkflow() = %v, want a WorkflowError", err)
+				}
+				if workflowErr.TimedOut != tt.timedOut || workflowErr.WID != tt.wid || workflowErr.Node != testNode {
+					t.Errorf("WaitForWorkflow() = %+v, want timed out: %t", workflowErr, tt.timedOut)
+				}
+			}
+
+			// the processing function is called at each poll while the node is processed (unknown count before a timeout)
+			if tt.processing >= 0 && processing != tt.processing {
+				t.Errorf("processing function called %d time(s), want %d", processing, tt.processing)
+			}
+		})
+	}
+}
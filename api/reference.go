@@ -0,0 +1,152 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	gopath "path"
+)
+
+// Site represents a site of the reference API
+type Site struct {
+	UID  string `json:"uid"`
+	Name string `json:"name"`
+}
+
+// siteCollection represents the response of the sites listing
+type siteCollection struct {
+	Items []Site `json:"items"`
+}
+
+// Cluster represents a cluster of the reference API
+type Cluster struct {
+	UID    string   `json:"uid"`
+	Queues []string `json:"queues"`
+	Exotic bool     `json:"exotic"`
+}
+
+// clusterCollection represents the response of the clusters listing
+type clusterCollection struct {
+	Items []Cluster `json:"items"`
+}
+
+// GetSite fetch and return the description of the site from the reference API
+func (c *Client) GetSite() (*Site, error) {
+	// send request
+	req, err := c.caller.R().
+		SetResult(&Site{}).
+		Get(c.getEndpoint("", "/", url.Values{}))
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving the site: '%s'", err)
+	}
+
+	// check HTTP error code (expected: 200 OK)
+	if req.StatusCode() != 200 {
+		return nil, fmt.Errorf("The server returned an error (code: %d) while fetching the site: '%s'", req.StatusCode(), req.Status())
+	}
+
+	// unmarshal result
+	site, ok := req.Result().(*Site)
+	if !ok {
+		return nil, fmt.Errorf("Error in the response of the site (unexpected type)")
+	}
+
+	return site, nil
+}
+
+// GetSites fetch and return all the sites of Grid'5000 from the reference API (whatever the site of the client)
+func (c *Client) GetSites() ([]Site, error) {
+	endpoint := c.baseURL
+	endpoint.Path = gopath.Join(g5kAPIversion, "sites")
+
+	// send request
+	req, err := c.caller.R().
+		SetResult(&siteCollection{}).
+		Get(endpoint.String())
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving the sites: '%s'", err)
+	}
+
+	// check HTTP error code (expected: 200 OK)
+	if req.StatusCode() != 200 {
+		return nil, fmt.Errorf("The server returned an error (code: %d) while fetching the sites: '%s'", req.StatusCode(), req.Status())
+	}
+
+	// unmarshal result
+	sites, ok := req.Result().(*siteCollection)
+	if !ok {
+		return nil, fmt.Errorf("Error in the response of the sites (unexpected type)")
+	}
+
+	return sites.Items, nil
+}
+
+// GetClusters fetch and return the clusters of the site from the reference API
+func (c *Client) GetClusters() ([]Cluster, error) {
+	// send request
+	req, err := c.caller.R().
+		SetResult(&clusterCollection{}).
+		Get(c.getEndpoint("clusters", "/", url.Values{}))
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving the clusters: '%s'", err)
+	}
+
+	// check HTTP error code (expected: 200 OK)
+	if req.StatusCode() != 200 {
+		return nil, fmt.Errorf("The server returned an error (code: %d) while fetching the clusters: '%s'", req.StatusCode(), req.Status())
+	}
+
+	// unmarshal result
+	clusters, ok := req.Result().(*clusterCollection)
+	if !ok {
+		return nil, fmt.Errorf("Error in the response of the clusters (unexpected type)")
+	}
+
+	return clusters.Items, nil
+}
+
+// Node represents a node of the reference API (only the main hardware characteristics)
+type Node struct {
+	UID          string `json:"uid"`
+	Architecture struct {
+		PlatformType string `json:"platform_type"`
+		NbProcs      int    `json:"nb_procs"`
+		NbCores      int    `json:"nb_cores"`
+		NbThreads    int    `json:"nb_threads"`
+	} `json:"architecture"`
+	Processor struct {
+		Model   string `json:"model"`
+		Version string `json:"version"`
+	} `json:"processor"`
+	MainMemory struct {
+		RAMSize int64 `json:"ram_size"`
+	} `json:"main_memory"`
+	Exotic bool `json:"exotic"`
+}
+
+// GetNode fetch and return the description of the node of the cluster from the reference API
+func (c *Client) GetNode(cluster string, uid string) (*Node, error) {
+	// send request
+	req, err := c.caller.R().
+		SetResult(&Node{}).
+		Get(c.getEndpoint("clusters", fmt.Sprintf("/%s/nodes/%s", cluster, uid), url.Values{}))
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving the node: '%s'", err)
+	}
+
+	// check HTTP error code (expected: 200 OK)
+	if req.StatusCode() != 200 {
+		return nil, fmt.Errorf("The server returned an error (code: %d) while fetching the node: '%s'", req.StatusCode(), req.Status())
+	}
+
+	// unmarshal result
+	node, ok := req.Result().(*Node)
+	if !ok {
+		return nil, fmt.Errorf("Error in the response of the node (unexpected type)")
+	}
+
+	return node, nil
+}
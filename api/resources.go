@@ -0,0 +1,188 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ResourceCountAll requests all the resources of a level (ex: "core=ALL")
+const ResourceCountAll int = -1
+
+// resourceLevelRanks stores the rank of the levels of the OAR resource hierarchy, a level can only contain levels of a higher rank
+var resourceLevelRanks = map[string]int{
+	"cluster": 0,
+	"switch":  1,
+	"nodes":   2,
+	"host":    2,
+	"cpu":     3,
+	"gpu":     3,
+	"disk":    3,
+	"core":    4,
+}
+
+// standaloneResourceLevels are the resources which are not part of the node hierarchy (they must be alone in their group)
+var standaloneResourceLevels = map[string]bool{"slash_22": true, "slash_18": true, "slash_16": true}
+
+// ResourceLevel represents a level of an OAR resource hierarchy (ex: "switch=1")
+type ResourceLevel struct {
+	Name  string
+	Count int
+}
+
+// IsPartOfNode returns true if the level selects a part of a node (ex: "core=8", "gpu=1")
+func (l ResourceLevel) IsPartOfNode() bool {
+	rank, ok := resourceLevelRanks[l.Name]
+	return ok && rank > resourceLevelRanks["nodes"]
+}
+
+// IsStandalone returns true if the level is not part of the node hierarchy and must be alone in its group (ex: "slash_22=1")
+func (l ResourceLevel) IsStandalone() bool {
+	return standaloneResourceLevels[l.Name]
+}
+
+// String returns the OAR expression of the level
+func (l ResourceLevel) String() string {
+	if l.Count == ResourceCountAll {
+		return l.Name + "=ALL"
+	}
+	return fmt.Sprintf("%s=%d", l.Name, l.Count)
+}
+
+// ResourceGroup represents a group of resources selected by an optional filter (ex: "{cluster='x'}/switch=1/nodes=2")
+type ResourceGroup struct {
+	Filter string
+	Levels []ResourceLevel
+}
+
+// String returns the OAR expression of the group
+func (g ResourceGroup) String() string {
+	levels := make([]string, 0, len(g.Levels))
+	for _, level := range g.Levels {
+		levels = append(levels, level.String())
+	}
+
+	expression := strings.Join(levels, "/")
+	if g.Filter != "" {
+		expression = fmt.Sprintf("{%s}/%s", g.Filter, expression)
+	}
+
+	return expression
+}
+
+// ResourceRequest represents the resources requested by a job, with their walltime
+type ResourceRequest struct {
+	Groups   []ResourceGroup
+	Walltime string
+}
+
+// NewResourceRequest returns a new resource request with the given walltime
+func NewResourceRequest(walltime string) *ResourceRequest {
+	return &ResourceRequest{Walltime: walltime}
+}
+
+// AddGroup add a group of resources to the request, the groups are requested together (ex: "slash_22=1+nodes=2")
+func (r *ResourceRequest) AddGroup(filter string, levels ...ResourceLevel) *ResourceRequest {
+	r.Groups = append(r.Groups, ResourceGroup{Filter: filter, Levels: levels})
+	return r
+}
+
+// String returns the OAR expression of the request, as expected by the 'resources' field of a job submission
+func (r *ResourceRequest) String() string {
+	groups := make([]string, 0, len(r.Groups))
+	for _, group := range r.Groups {
+		groups = append(groups, group.String())
+	}
+
+	return fmt.Sprintf("%s,walltime=%s", strings.Join(groups, "+"), r.Walltime)
+}
+
+// Validate check that the request is a valid OAR resource expression
+func (r *ResourceRequest) Validate() error {
+	if len(r.Groups) == 0 {
+		return fmt.Errorf("The resource request must contain at least one group of resources")
+	}
+
+	if r.Walltime == "" {
+		return fmt.Errorf("The resource request must have a walltime")
+	}
+
+	for _, group := range r.Groups {
+		if err := group.validate(); err != nil {
+			return fmt.Errorf("Invalid resources '%s': %s", group, err)
+		}
+	}
+
+	return nil
+}
+
+// validate check the filter and the hierarchy of the group
+func (g ResourceGroup) validate() error {
+	if len(g.Levels) == 0 {
+		return fmt.Errorf("The group must contain at least one level")
+	}
+
+	if strings.ContainsAny(g.Filter, "{}") {
+		return fmt.Errorf("The filter must not contain braces")
+	}
+	if strings.Count(g.Filter, "'")%2 != 0 {
+		return fmt.Errorf("The filter contains an unterminated quoted value")
+	}
+
+	previousRank := -1
+	for _, level := range g.Levels {
+		if level.Count <= 0 && level.Count != ResourceCountAll {
+			return fmt.Errorf("The count of the '%s' level must be positive", level.Name)
+		}
+
+		if standaloneResourceLevels[level.Name] {
+			if len(g.Levels) != 1 {
+				return fmt.Errorf("The '%s' level must be alone in its group", level.Name)
+			}
+			continue
+		}
+
+		rank, ok := resourceLevelRanks[level.Name]
+		if !ok {
+			return fmt.Errorf("The '%s' level is not supported", level.Name)
+		}
+
+		// each level must be contained in the previous one (ex: "switch=1/nodes=2", not "nodes=2/switch=1")
+		if rank <= previousRank {
+			return fmt.Errorf("The '%s' level cannot be contained in the previous level", level.Name)
+		}
+		previousRank = rank
+	}
+
+	return nil
+}
+
+// ParseResourceLevels parse a hierarchy of resource levels (ex: "cluster=1/switch=1")
+func ParseResourceLevels(expression string) ([]ResourceLevel, error) {
+	levels := []ResourceLevel{}
+	if expression == "" {
+		return levels, nil
+	}
+
+	for _, item := range strings.Split(expression, "/") {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("The resource level '%s' must have the 'name=count' format", item)
+		}
+
+		name := strings.TrimSpace(parts[0])
+		countValue := strings.TrimSpace(parts[1])
+
+		count := ResourceCountAll
+		if countValue != "ALL" {
+			var err error
+			if count, err = strconv.Atoi(countValue); err != nil {
+				return nil, fmt.Errorf("The count of the resource level '%s' must be a number or 'ALL'", item)
+			}
+		}
+
+		levels = append(levels, ResourceLevel{Name: name, Count: count})
+	}
+
+	return levels, nil
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseResourceLevels(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       []ResourceLevel
+		wantErr    bool
+	}{
+		{"", []ResourceLevel{}, false},
+		{"switch=1", []ResourceLevel{{"switch", 1}}, false},
+		{"cluster=1/switch=2", []ResourceLevel{{"cluster", 1}, {"switch", 2}}, false},
+		{" cluster = 1 / nodes = ALL ", []ResourceLevel{{"cluster", 1}, {"nodes", ResourceCountAll}}, false},
+		{"switch", nil, true},
+		{"switch=one", nil, true},
+		{"switch=all", nil, true},
+		{"cluster=1/", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseResourceLevels(tt.expression)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseResourceLevels(%q) error = %v, want error: %t", tt.expression, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseResourceLevels(%q) = %v, want %v", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestResourceRequestString(t *testing.T) {
+	request := NewResourceRequest("1:00:00").
+		AddGroup("", ResourceLevel{"slash_22", 1}).
+		AddGroup("gpu_count >= 1", ResourceLevel{"cluster", 1}, ResourceLevel{"nodes", 2})
+
+	want := "slash_22=1+{gpu_count >= 1}/cluster=1/nodes=2,walltime=1:00:00"
+	if got := request.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestResourceRequestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *ResourceRequest
+		wantErr bool
+	}{
+		{"nodes", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"nodes", 1}), false},
+		{"all nodes", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"nodes", ResourceCountAll}), false},
+		{"hierarchy", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"cluster", 1}, ResourceLevel{"switch", 1}, ResourceLevel{"nodes", 2}), false},
+		{"filter", NewResourceRequest("1:00:00").AddGroup("cluster='paravance' and gpu_count >= 1", ResourceLevel{"nodes", 1}), false},
+		{"subnet group", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"slash_22", 1}).AddGroup("", ResourceLevel{"nodes", 1}), false},
+		{"no group", NewResourceRequest("1:00:00"), true},
+		{"no walltime", NewResourceRequest("").AddGroup("", ResourceLevel{"nodes", 1}), true},
+		{"no level", NewResourceRequest("1:00:00").AddGroup("cluster='paravance'"), true},
+		{"zero count", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"nodes", 0}), true},
+		{"negative count", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"nodes", -2}), true},
+		{"inverted hierarchy", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"nodes", 2}, ResourceLevel{"switch", 1}), true},
+		{"repeated level", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"switch", 1}, ResourceLevel{"switch", 1}), true},
+		{"nodes and host", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"nodes", 1}, ResourceLevel{"host", 1}), true},
+		{"cores of a node", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"nodes", 1}, ResourceLevel{"core", 8}), false},
+		{"gpu of a node", NewResourceRequest("1:00:00").AddGroup("cluster='chifflot'", ResourceLevel{"host", 1}, ResourceLevel{"gpu", 1}), false},
+		{"cores of a cpu", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"cpu", 1}, ResourceLevel{"core", ResourceCountAll}), false},
+		{"disk", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"nodes", 1}, ResourceLevel{"disk", 1}), false},
+		{"core containing a cpu", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"core", 2}, ResourceLevel{"cpu", 1}), true},
+		{"gpu and disk", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"gpu", 1}, ResourceLevel{"disk", 1}), true},
+		{"unknown level", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"rack", 1}), true},
+		{"subnet not alone", NewResourceRequest("1:00:00").AddGroup("", ResourceLevel{"slash_22", 1}, ResourceLevel{"nodes", 1}), true},
+		{"braces in filter", NewResourceRequest("1:00:00").AddGroup("cluster='a'}/{cluster='b'", ResourceLevel{"nodes", 1}), true},
+		{"unterminated quote", NewResourceRequest("1:00:00").AddGroup("cluster='paravance", ResourceLevel{"nodes", 1}), true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.request.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate() of the %s request (%s) error = %v, want error: %t", tt.name, tt.request, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResourceLevelKind(t *testing.T) {
+	tests := []struct {
+		level      ResourceLevel
+		partOfNode bool
+		standalone bool
+	}{
+		{ResourceLevel{"cluster", 1}, false, false},
+		{ResourceLevel{"nodes", 2}, false, false},
+		{ResourceLevel{"host", 1}, false, false},
+		{ResourceLevel{"cpu", 1}, true, false},
+		{ResourceLevel{"gpu", 1}, true, false},
+		{ResourceLevel{"disk", 1}, true, false},
+		{ResourceLevel{"core", 8}, true, false},
+		{ResourceLevel{"slash_22", 1}, false, true},
+		{ResourceLevel{"rack", 1}, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.level.IsPartOfNode(); got != tt.partOfNode {
+			t.Errorf("IsPartOfNode() of %s = %t, want %t", tt.level, got, tt.partOfNode)
+		}
+		if got := tt.level.IsStandalone(); got != tt.standalone {
+			t.Errorf("IsStandalone() of %s = %t, want %t", tt.level, got, tt.standalone)
+		}
+	}
+}
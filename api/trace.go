@@ -0,0 +1,216 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// redactedValue replaces the sensitive values in the traced exchanges
+const redactedValue string = "[REDACTED]"
+
+// sensitiveHeaders are the headers removed from the traced exchanges
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// sensitiveFieldRegexp matches the name of the JSON fields whose value is redacted in the traced bodies
+var sensitiveFieldRegexp = regexp.MustCompile(`(?i)password|secret|token|key`)
+
+// Exchange represents a request sent to the API and its response, without the credentials
+type Exchange struct {
+	StartedAt       time.Time
+	Duration        time.Duration
+	Method          string
+	URL             *url.URL
+	RequestHeaders  http.Header
+	RequestBody     string
+	StatusCode      int
+	Status          string
+	ResponseHeaders http.Header
+	ResponseBody    string
+	Error           string
+}
+
+// redactHeaders returns a copy of the headers without the sensitive ones
+func redactHeaders(headers http.Header) http.Header {
+	redacted := headers.Clone()
+	if redacted == nil {
+		redacted = http.Header{}
+	}
+
+	for _, name := range sensitiveHeaders {
+		redacted.Del(name)
+	}
+
+	return redacted
+}
+
+// redactJSONValue replace the value of the sensitive fields in the given decoded JSON value
+func redactJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for field, fieldValue := range v {
+			if sensitiveFieldRegexp.MatchString(field) {
+				v[field] = redactedValue
+			} else {
+				v[field] = redactJSONValue(fieldValue)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactJSONValue(item)
+		}
+	}
+	return value
+}
+
+// redactBody returns the body with the value of the sensitive fields redacted (only for JSON bodies)
+func redactBody(body []byte) string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return string(body)
+	}
+
+	redacted, err := json.Marshal(redactJSONValue(value))
+	if err != nil {
+		return string(body)
+	}
+
+	return string(redacted)
+}
+
+// roundTripExchange send the request with the given transport and returns the response with the redacted exchange
+func roundTripExchange(transport http.RoundTripper, req *http.Request) (*http.Response, *Exchange, error) {
+	exchange := &Exchange{
+		StartedAt:      time.Now(),
+		Method:         req.Method,
+		URL:            req.URL,
+		RequestHeaders: redactHeaders(req.Header),
+	}
+
+	// read the request body and restore it for the transport
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		exchange.RequestBody = redactBody(body)
+	}
+
+	res, err := transport.RoundTrip(req)
+	exchange.Duration = time.Since(exchange.StartedAt)
+	if err != nil {
+		exchange.Error = err.Error()
+		return nil, exchange, err
+	}
+
+	// read the response body and restore it for the client
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		exchange.Error = err.Error()
+		return nil, exchange, err
+	}
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	exchange.StatusCode = res.StatusCode
+	exchange.Status = res.Status
+	exchange.ResponseHeaders = redactHeaders(res.Header)
+	exchange.ResponseBody = redactBody(body)
+
+	return res, exchange, nil
+}
+
+// tracingTransport is a HTTP transport logging the exchanges with the API, they can also be saved to an archive file
+type tracingTransport struct {
+	transport http.RoundTripper
+	logf      func(format string, args ...interface{})
+	harPath   string
+	scrubber  *regexp.Regexp
+	mutex     sync.Mutex
+}
+
+// truncateBody returns the body truncated to a length suitable for the logs
+func truncateBody(body string) string {
+	if len(body) > 2048 {
+		return body[:2048] + "...(truncated)"
+	}
+	return body
+}
+
+// RoundTrip send the request and trace the exchange
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, exchange, err := roundTripExchange(t.transport, req)
+	if exchange == nil {
+		return res, err
+	}
+
+	if t.scrubber != nil {
+		exchange = scrubExchange(exchange, t.scrubber)
+	}
+
+	if t.logf != nil {
+		t.logExchange(exchange, err)
+	}
+
+	if t.harPath != "" {
+		if harErr := t.appendToHAR(exchange); harErr != nil && t.logf != nil {
+			t.logf("Failed to save the API exchange to '%s': %s", t.harPath, harErr)
+		}
+	}
+
+	return res, err
+}
+
+// logExchange log the exchange with the log function of the transport
+func (t *tracingTransport) logExchange(exchange *Exchange, err error) {
+	if err != nil {
+		t.logf("API request %s %s failed after %s: %s", exchange.Method, exchange.URL, exchange.Duration, err)
+	} else {
+		t.logf("API request %s %s returned '%s' in %s", exchange.Method, exchange.URL, exchange.Status, exchange.Duration)
+	}
+
+	if exchange.RequestBody != "" {
+		t.logf("API request body: %s", truncateBody(exchange.RequestBody))
+	}
+	if exchange.ResponseBody != "" {
+		t.logf("API response body: %s", truncateBody(exchange.ResponseBody))
+	}
+}
+
+// appendToHAR append the exchange to the archive file
+func (t *tracingTransport) appendToHAR(exchange *Exchange) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	har, err := LoadOrCreateHAR(t.harPath)
+	if err != nil {
+		return fmt.Errorf("Failed to load the archive: %s", err)
+	}
+
+	har.Log.Entries = append(har.Log.Entries, newHAREntry(exchange))
+	return har.Save(t.harPath)
+}
+
+// EnableTracing log every request sent to the API and its response using the given log function
+// The credentials are removed from the traces, which are also appended to the given archive file if its path is not empty
+func (c *Client) EnableTracing(logf func(format string, args ...interface{}), harPath string) {
+	transport := c.caller.GetClient().Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	c.caller.SetTransport(&tracingTransport{
+		transport: transport,
+		logf:      logf,
+		harPath:   harPath,
+	})
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Spirals-Team/docker-machine-driver-g5k/driver"
+)
+
+// Actions taken on the orphaned jobs
+const (
+	cleanupActionNone   string = "none"
+	cleanupActionKilled string = "killed"
+	cleanupActionFailed string = "failed"
+)
+
+// orphanedJob represents a job submitted by the driver which is not used by any machine
+type orphanedJob struct {
+	Site      string `json:"site"`
+	JobID     int    `json:"job_id"`
+	Machine   string `json:"machine"`
+	State     string `json:"state"`
+	StartTime string `json:"start_time,omitempty"`
+	Action    string `json:"action"`
+	Error     string `json:"error,omitempty"`
+}
+
+// getUsedJobs returns the jobs used by the machines of the store or listed in the reservations registry, indexed by site and job ID
+func getUsedJobs() (map[string]bool, error) {
+	used := make(map[string]bool)
+
+	machineJobs, err := driver.LoadMachineJobs(getStorePath())
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range machineJobs {
+		used[job.Site+"/"+strconv.Itoa(job.JobID)] = true
+	}
+
+	// the reservations do not always have a machine (pending, or used by a machine whose registry update failed)
+	reservations, err := driver.NewReservationRegistry(getStorePath()).Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, reservation := range reservations {
+		used[reservation.Site+"/"+strconv.Itoa(reservation.JobID)] = true
+	}
+
+	return used, nil
+}
+
+// findOrphanedJobs returns the jobs of the user on the site submitted by the driver for the store and not used by any machine
+func findOrphanedJobs(site string, used map[string]bool) ([]orphanedJob, error) {
+	client, err := newAPIClient(site)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := client.GetJobs(getUsername(), []string{"running", "waiting", "launching", "hold"})
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := []orphanedJob{}
+	for _, job := range jobs {
+		machine, ok := driver.ParseJobName(getStorePath(), job.Name)
+		if !ok || used[site+"/"+strconv.Itoa(job.UID)] {
+			continue
+		}
+
+		orphans = append(orphans, orphanedJob{
+			Site:      site,
+			JobID:     job.UID,
+			Machine:   machine,
+			State:     job.State,
+			StartTime: formatTimestamp(job.StartTime),
+			Action:    cleanupActionNone,
+		})
+	}
+
+	return orphans, nil
+}
+
+// cleanupJobs list the jobs submitted by the driver which are not used by any machine, and kill them if requested
+func cleanupJobs(flags *flag.FlagSet, args []string) error {
+	site := flags.String("site", "", "Only clean up the jobs of the site (all the sites if empty)")
+	kill := flags.Bool("kill", false, "Kill the orphaned jobs (they are only listed otherwise)")
+	format := addFormatFlag(flags)
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	if _, _, err := getCredentials(); err != nil {
+		return err
+	}
+
+	used, err := getUsedJobs()
+	if err != nil {
+		return err
+	}
+
+	sites := []string{*site}
+	if *site == "" {
+		if sites, err = getSites(); err != nil {
+			return err
+		}
+	}
+
+	orphans := []orphanedJob{}
+	for _, s := range sites {
+		// a site can be unavailable, the other sites are still cleaned up
+		siteOrphans, err := findOrphanedJobs(s, used)
+		if err != nil {
+			fmt.Fprintf(stderr, "Warning: the jobs of the '%s' site are not available: %s\n", s, err)
+			continue
+		}
+		orphans = append(orphans, siteOrphans...)
+	}
+
+	if *kill {
+		for i := range orphans {
+			client, err := newAPIClient(orphans[i].Site)
+			if err == nil {
+				err = client.KillJob(orphans[i].JobID)
+			}
+
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: failed to kill the job %d of the '%s' site: %s\n", orphans[i].JobID, orphans[i].Site, err)
+				orphans[i].Action = cleanupActionFailed
+				orphans[i].Error = err.Error()
+			} else {
+				orphans[i].Action = cleanupActionKilled
+			}
+		}
+	}
+
+	if err := printResult(*format, orphans, func(table *tabwriter.Writer) {
+		fmt.Fprintln(table, "SITE\tJOB ID\tMACHINE\tSTATE\tSTART TIME\tACTION")
+		for _, orphan := range orphans {
+			fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%s\t%s\n", orphan.Site, orphan.JobID, orphan.Machine, orphan.State, orphan.StartTime, orphan.Action)
+		}
+	}); err != nil {
+		return err
+	}
+
+	if !*kill && len(orphans) > 0 {
+		fmt.Fprintf(stderr, "Nothing has been killed, run the command again with the '--kill' flag to kill the %d orphaned job(s)\n", len(orphans))
+	}
+
+	for _, orphan := range orphans {
+		if orphan.Action == cleanupActionFailed {
+			return fmt.Errorf("Failed to kill some of the orphaned jobs")
+		}
+	}
+
+	return nil
+}
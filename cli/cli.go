@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Spirals-Team/docker-machine-driver-g5k/api"
+	"github.com/Spirals-Team/docker-machine-driver-g5k/driver"
+	"github.com/docker/machine/commands/mcndirs"
+)
+
+// command represents a command of the standalone mode, with its subcommands
+type command struct {
+	name        string
+	description string
+	subcommands []subcommand
+}
+
+// subcommand represents an action of a command
+type subcommand struct {
+	name        string
+	usage       string
+	description string
+	run         func(flags *flag.FlagSet, args []string) error
+}
+
+// commands are the commands of the standalone mode
+var commands = []command{
+	{
+		name:        "jobs",
+		description: "Manage the jobs of the user",
+		subcommands: jobsSubcommands,
+	},
+	{
+		name:        "deploy",
+		description: "Follow the deployments",
+		subcommands: deploySubcommands,
+	},
+	{
+		name:        "power",
+		description: "Manage the power status of the nodes",
+		subcommands: powerSubcommands,
+	},
+	{
+		name:        "nodes",
+		description: "Show the description of the nodes",
+		subcommands: nodesSubcommands,
+	},
+	{
+		name:        "reservations",
+		description: "Manage the reservations made through the driver",
+		subcommands: reservationsSubcommands,
+	},
+}
+
+// stdout and stderr are the outputs of the commands
+var (
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+// printUsage print the usage of the standalone mode
+func printUsage() {
+	fmt.Fprintf(stderr, "Usage: docker-machine-driver-g5k <command> <subcommand> [flags] [args]\n\n")
+	fmt.Fprintf(stderr, "The Grid'5000 credentials are read from the G5K_USERNAME and G5K_PASSWORD environment variables, the default site from G5K_SITE.\n")
+	fmt.Fprintf(stderr, "The results are printed as a table, or in JSON format with the '--format json' flag.\n\n")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(stderr, "%s: %s\n", cmd.name, cmd.description)
+		for _, sub := range cmd.subcommands {
+			fmt.Fprintf(stderr, "  %s %s %s\n", cmd.name, sub.name, sub.usage)
+			fmt.Fprintf(stderr, "      %s\n", sub.description)
+		}
+	}
+}
+
+// Run execute the command given by the arguments and returns the exit code
+func Run(args []string) int {
+	if len(args) < 2 {
+		printUsage()
+		return 2
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		for _, sub := range cmd.subcommands {
+			if sub.name != args[1] {
+				continue
+			}
+
+			flags := flag.NewFlagSet(strings.Join(args[:2], " "), flag.ContinueOnError)
+			flags.SetOutput(stderr)
+			if err := sub.run(flags, args[2:]); err != nil {
+				if err != flag.ErrHelp {
+					fmt.Fprintf(stderr, "Error: %s\n", err)
+				}
+				return 1
+			}
+
+			return 0
+		}
+	}
+
+	printUsage()
+	return 2
+}
+
+// getStorePath returns the path of the docker-machine store, where the driver store is located
+func getStorePath() string {
+	return mcndirs.GetBaseDir()
+}
+
+// getUsername returns the username given by the environment
+func getUsername() string {
+	return os.Getenv("G5K_USERNAME")
+}
+
+// getCredentials returns the Grid'5000 credentials given by the environment
+func getCredentials() (string, string, error) {
+	username := getUsername()
+	password := os.Getenv("G5K_PASSWORD")
+	if username == "" || password == "" {
+		return "", "", fmt.Errorf("The G5K_USERNAME and G5K_PASSWORD environment variables must be set")
+	}
+
+	return username, password, nil
+}
+
+// newAPIClient returns a new Grid'5000 API client for the site, with the credentials given by the environment
+func newAPIClient(site string) (*api.Client, error) {
+	username, password, err := getCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	if site == "" {
+		return nil, fmt.Errorf("The site must be given with the '--site' flag or the G5K_SITE environment variable")
+	}
+
+	return driver.NewAPIClient(username, password, site, false, "")
+}
+
+// getSites returns the UID of all the sites of Grid'5000
+func getSites() ([]string, error) {
+	username, password, err := getCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := driver.NewAPIClient(username, password, "", false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	sites, err := client.GetSites()
+	if err != nil {
+		return nil, err
+	}
+
+	uids := []string{}
+	for _, site := range sites {
+		uids = append(uids, site.UID)
+	}
+
+	return uids, nil
+}
+
+// addSiteFlag add the flag selecting the site to the subcommand flags
+func addSiteFlag(flags *flag.FlagSet) *string {
+	return flags.String("site", os.Getenv("G5K_SITE"), "Grid'5000 site")
+}
+
+// parseFlags parse the flags of the subcommand and check the number of remaining arguments
+func parseFlags(flags *flag.FlagSet, args []string, nargs int) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if flags.NArg() != nargs {
+		return nil, fmt.Errorf("The '%s' command expects %d argument(s), got %d", flags.Name(), nargs, flags.NArg())
+	}
+
+	return flags.Args(), nil
+}
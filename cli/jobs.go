@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Spirals-Team/docker-machine-driver-g5k/api"
+	"github.com/Spirals-Team/docker-machine-driver-g5k/driver"
+)
+
+// jobsSubcommands are the actions of the 'jobs' command
+var jobsSubcommands = []subcommand{
+	{
+		name:        "list",
+		usage:       "[--site SITE] [--state STATES] [--format FORMAT]",
+		description: "List the jobs of the user (running and waiting by default)",
+		run:         listJobs,
+	},
+	{
+		name:        "show",
+		usage:       "[--site SITE] [--format FORMAT] <job-id>",
+		description: "Show a job",
+		run:         showJob,
+	},
+	{
+		name:        "kill",
+		usage:       "[--site SITE] <job-id>",
+		description: "Kill a job",
+		run:         killJob,
+	},
+	{
+		name:        "extend",
+		usage:       "[--site SITE] <job-id> <duration>",
+		description: "Request an extension of the walltime of a job (ex: '1:00:00', '30m')",
+		run:         extendJob,
+	},
+	{
+		name:        "cleanup",
+		usage:       "[--site SITE] [--kill] [--format FORMAT]",
+		description: "List the jobs submitted by the driver which are not used by any machine (on all the sites by default), and kill them with '--kill'",
+		run:         cleanupJobs,
+	},
+}
+
+// parseJobID parse the job ID given as argument
+func parseJobID(value string) (int, error) {
+	jobID, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("The job ID '%s' must be a number", value)
+	}
+	return jobID, nil
+}
+
+// formatTimestamp returns the UNIX timestamp as a date in the Grid'5000 timezone, or an empty string if it is not set
+func formatTimestamp(timestamp int) string {
+	if timestamp == 0 {
+		return ""
+	}
+	return driver.FormatReservationDate(time.Unix(int64(timestamp), 0))
+}
+
+// listJobs print the jobs of the user
+func listJobs(flags *flag.FlagSet, args []string) error {
+	site := addSiteFlag(flags)
+	states := flags.String("state", "running,waiting,launching,hold", "Comma separated states of the listed jobs (all the states if empty)")
+	format := addFormatFlag(flags)
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	client, err := newAPIClient(*site)
+	if err != nil {
+		return err
+	}
+
+	filter := []string{}
+	if *states != "" {
+		filter = strings.Split(*states, ",")
+	}
+
+	jobs, err := client.GetJobs(getUsername(), filter)
+	if err != nil {
+		return err
+	}
+
+	return printResult(*format, jobs, func(table *tabwriter.Writer) {
+		fmt.Fprintln(table, "JOB ID\tNAME\tSTATE\tQUEUE\tWALLTIME\tSTART TIME\tNODES")
+		for _, job := range jobs {
+			fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", job.UID, job.Name, job.State, job.Queue, driver.FormatWalltime(time.Duration(job.Timelife)*time.Second), formatTimestamp(job.StartTime), strings.Join(job.Nodes, ","))
+		}
+	})
+}
+
+// showJob print a job
+func showJob(flags *flag.FlagSet, args []string) error {
+	site := addSiteFlag(flags)
+	format := addFormatFlag(flags)
+	args, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	jobID, err := parseJobID(args[0])
+	if err != nil {
+		return err
+	}
+
+	client, err := newAPIClient(*site)
+	if err != nil {
+		return err
+	}
+
+	job, err := client.GetJob(jobID)
+	if err != nil {
+		return err
+	}
+
+	return printResult(*format, job, func(table *tabwriter.Writer) {
+		writeJobFields(table, job)
+	})
+}
+
+// writeJobFields write the fields of the job as a table
+func writeJobFields(table *tabwriter.Writer, job *api.Job) {
+	writeFields(table, [][2]string{
+		{"Job ID", strconv.Itoa(job.UID)},
+		{"Name", job.Name},
+		{"Project", job.Project},
+		{"User", job.User},
+		{"State", job.State},
+		{"Queue", job.Queue},
+		{"Types", strings.Join(job.Types, ",")},
+		{"Walltime", driver.FormatWalltime(time.Duration(job.Timelife) * time.Second)},
+		{"Scheduled at", formatTimestamp(job.ScheduledAt)},
+		{"Started at", formatTimestamp(job.StartTime)},
+		{"Nodes", strings.Join(job.Nodes, ",")},
+	})
+}
+
+// killJob kill a job
+func killJob(flags *flag.FlagSet, args []string) error {
+	site := addSiteFlag(flags)
+	args, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	jobID, err := parseJobID(args[0])
+	if err != nil {
+		return err
+	}
+
+	client, err := newAPIClient(*site)
+	if err != nil {
+		return err
+	}
+
+	if err := client.KillJob(jobID); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "The job %d has been killed\n", jobID)
+	return nil
+}
+
+// extendJob request an extension of the walltime of a job
+func extendJob(flags *flag.FlagSet, args []string) error {
+	site := addSiteFlag(flags)
+	args, err := parseFlags(flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	jobID, err := parseJobID(args[0])
+	if err != nil {
+		return err
+	}
+
+	extension, err := driver.ParseWalltime(args[1])
+	if err != nil {
+		return err
+	}
+
+	client, err := newAPIClient(*site)
+	if err != nil {
+		return err
+	}
+
+	if err := client.ExtendJob(jobID, "+"+driver.FormatWalltime(extension)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "The extension of the walltime of the job %d by %s has been requested\n", jobID, driver.FormatWalltime(extension))
+	return nil
+}
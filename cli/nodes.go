@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// nodesSubcommands are the actions of the 'nodes' command
+var nodesSubcommands = []subcommand{
+	{
+		name:        "show",
+		usage:       "[--site SITE] [--format FORMAT] <node>",
+		description: "Show the hardware description of a node (ex: 'chifflet-1' or 'chifflet-1.lille.grid5000.fr')",
+		run:         showNode,
+	},
+}
+
+// getNodeSite returns the site of the node from its hostname (ex: 'chifflet-1.lille.grid5000.fr'), or the given default site
+func getNodeSite(node string, defaultSite string) string {
+	labels := strings.Split(node, ".")
+	if len(labels) >= 2 && strings.HasSuffix(node, ".grid5000.fr") {
+		return labels[1]
+	}
+	return defaultSite
+}
+
+// parseNodeName returns the cluster and the UID of the node from its hostname (ex: 'chifflet-1.lille.grid5000.fr')
+func parseNodeName(node string) (string, string, error) {
+	uid := strings.Split(node, ".")[0]
+
+	i := strings.LastIndex(uid, "-")
+	if i <= 0 {
+		return "", "", fmt.Errorf("The node name '%s' must have the '<cluster>-<number>' format", node)
+	}
+
+	return uid[:i], uid, nil
+}
+
+// showNode print the hardware description of a node
+func showNode(flags *flag.FlagSet, args []string) error {
+	site := addSiteFlag(flags)
+	format := addFormatFlag(flags)
+	args, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	cluster, uid, err := parseNodeName(args[0])
+	if err != nil {
+		return err
+	}
+
+	client, err := newAPIClient(getNodeSite(args[0], *site))
+	if err != nil {
+		return err
+	}
+
+	node, err := client.GetNode(cluster, uid)
+	if err != nil {
+		return err
+	}
+
+	return printResult(*format, node, func(table *tabwriter.Writer) {
+		writeFields(table, [][2]string{
+			{"Node", node.UID},
+			{"Cluster", cluster},
+			{"Platform", node.Architecture.PlatformType},
+			{"Processor", strings.TrimSpace(node.Processor.Model + " " + node.Processor.Version)},
+			{"CPUs", strconv.Itoa(node.Architecture.NbProcs)},
+			{"Cores", strconv.Itoa(node.Architecture.NbCores)},
+			{"Threads", strconv.Itoa(node.Architecture.NbThreads)},
+			{"Memory", fmt.Sprintf("%d GiB", node.MainMemory.RAMSize/(1<<30))},
+			{"Exotic", strconv.FormatBool(node.Exotic)},
+		})
+	})
+}
@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Spirals-Team/docker-machine-driver-g5k/api"
+)
+
+// deploySubcommands are the actions of the 'deploy' command
+var deploySubcommands = []subcommand{
+	{
+		name:        "status",
+		usage:       "[--site SITE] [--format FORMAT] <workflow-id>",
+		description: "Show the status of each node of a deployment",
+		run:         showDeploymentStatus,
+	},
+}
+
+// powerSubcommands are the actions of the 'power' command
+var powerSubcommands = []subcommand{
+	{
+		name:        "status",
+		usage:       "[--site SITE] [--format FORMAT] <node>",
+		description: "Show the power status of a node, by querying its BMC",
+		run:         showPowerStatus,
+	},
+	{
+		name:        "on",
+		usage:       "[--site SITE] [--level LEVEL] <node>",
+		description: "Power on a node",
+		run:         func(flags *flag.FlagSet, args []string) error { return changePowerStatus(flags, args, "on") },
+	},
+	{
+		name:        "off",
+		usage:       "[--site SITE] [--level LEVEL] <node>",
+		description: "Power off a node",
+		run:         func(flags *flag.FlagSet, args []string) error { return changePowerStatus(flags, args, "off") },
+	},
+}
+
+// nodeStatus represents the status of a node in an operation workflow
+type nodeStatus struct {
+	Node   string `json:"node"`
+	Result string `json:"result"`
+	Step   string `json:"step,omitempty"`
+}
+
+// powerStatus represents the power status of a node
+type powerStatus struct {
+	Node   string `json:"node"`
+	Status string `json:"status"`
+}
+
+// workflowTimeout is the maximum duration of the workflow of a power operation
+const workflowTimeout time.Duration = 10 * time.Minute
+
+// waitForWorkflow wait until the workflow of the operation is done for the node
+func waitForWorkflow(client *api.Client, operation string, wid string, node string) error {
+	return client.WaitForWorkflow(operation, wid, node, 3*time.Second, workflowTimeout, nil)
+}
+
+// showDeploymentStatus print the status of each node of a deployment
+func showDeploymentStatus(flags *flag.FlagSet, args []string) error {
+	site := addSiteFlag(flags)
+	format := addFormatFlag(flags)
+	args, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	client, err := newAPIClient(*site)
+	if err != nil {
+		return err
+	}
+
+	workflow, err := client.GetOperationWorkflow("deployment", args[0])
+	if err != nil {
+		return err
+	}
+
+	// the current step of each node is only informative
+	states, err := client.GetOperationStates("deployment", args[0])
+	if err != nil {
+		states = &api.OperationStates{}
+	}
+
+	statuses := []nodeStatus{}
+	for result, nodes := range workflow.Nodes {
+		for _, node := range nodes {
+			status := nodeStatus{Node: node, Result: result}
+			if state, ok := (*states)[node]; ok {
+				status.Step = strings.Trim(state.Macro+"/"+state.Micro, "/")
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Node < statuses[j].Node })
+
+	return printResult(*format, statuses, func(table *tabwriter.Writer) {
+		fmt.Fprintln(table, "NODE\tRESULT\tSTEP")
+		for _, status := range statuses {
+			fmt.Fprintf(table, "%s\t%s\t%s\n", status.Node, status.Result, status.Step)
+		}
+	})
+}
+
+// showPowerStatus print the power status of a node
+func showPowerStatus(flags *flag.FlagSet, args []string) error {
+	site := addSiteFlag(flags)
+	format := addFormatFlag(flags)
+	args, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	node := args[0]
+	client, err := newAPIClient(getNodeSite(node, *site))
+	if err != nil {
+		return err
+	}
+
+	op, err := client.RequestPowerStatus(node)
+	if err != nil {
+		return err
+	}
+
+	if err := waitForWorkflow(client, "power", op.WID, node); err != nil {
+		return err
+	}
+
+	states, err := client.GetOperationStates("power", op.WID)
+	if err != nil {
+		return err
+	}
+
+	status, err := states.PowerStatus(node)
+	if err != nil {
+		return err
+	}
+
+	result := powerStatus{Node: node, Status: status}
+	return printResult(*format, result, func(table *tabwriter.Writer) {
+		fmt.Fprintln(table, "NODE\tSTATUS")
+		fmt.Fprintf(table, "%s\t%s\n", result.Node, result.Status)
+	})
+}
+
+// changePowerStatus power on or off a node
+func changePowerStatus(flags *flag.FlagSet, args []string, status string) error {
+	site := addSiteFlag(flags)
+	level := flags.String("level", "soft", "Level of the operation ('soft' or 'hard')")
+	args, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	node := args[0]
+	client, err := newAPIClient(getNodeSite(node, *site))
+	if err != nil {
+		return err
+	}
+
+	op, err := client.SubmitPowerOperation(api.PowerOperation{
+		Nodes:  []string{node},
+		Status: status,
+		Level:  *level,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := waitForWorkflow(client, "power", op.WID, node); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "The '%s' node has been powered %s\n", node, status)
+	return nil
+}
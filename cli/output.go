@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+)
+
+// Output formats of the results
+const (
+	formatTable string = "table"
+	formatJSON  string = "json"
+)
+
+// addFormatFlag add the flag selecting the output format to the subcommand flags
+func addFormatFlag(flags *flag.FlagSet) *string {
+	return flags.String("format", formatTable, "Output format ('table' or 'json')")
+}
+
+// printResult print the result in JSON format, or as a table written by the given function
+func printResult(format string, result interface{}, writeTable func(table *tabwriter.Writer)) error {
+	switch format {
+	case formatJSON:
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(stdout, string(data))
+		return nil
+	case formatTable:
+		table := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		writeTable(table)
+		return table.Flush()
+	default:
+		return fmt.Errorf("The output format must be either '%s' or '%s'", formatTable, formatJSON)
+	}
+}
+
+// writeFields write the given fields and their values as a two columns table
+func writeFields(table *tabwriter.Writer, fields [][2]string) {
+	for _, field := range fields {
+		fmt.Fprintf(table, "%s:\t%s\n", field[0], field[1])
+	}
+}
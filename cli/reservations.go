@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Spirals-Team/docker-machine-driver-g5k/driver"
+)
+
+// reservationsSubcommands are the actions of the 'reservations' command
+var reservationsSubcommands = []subcommand{
+	{
+		name:        "list",
+		usage:       "[--site SITE] [--format FORMAT]",
+		description: "List the reservations made through the driver",
+		run:         listReservations,
+	},
+	{
+		name:        "inspect",
+		usage:       "[--site SITE] [--format FORMAT] <name|job-id>",
+		description: "Show a reservation and the current state of its job",
+		run:         inspectReservation,
+	},
+	{
+		name:        "cancel",
+		usage:       "[--site SITE] <name|job-id>",
+		description: "Kill the job of a reservation and mark it as cancelled",
+		run:         cancelReservation,
+	},
+}
+
+// reservationDetails represents a reservation with the current state of its job
+type reservationDetails struct {
+	driver.Reservation
+	State    string `json:"state"`
+	JobState string `json:"job_state,omitempty"`
+}
+
+// findReservation returns the reservation with the given name or job ID from the registry
+func findReservation(registry *driver.ReservationRegistry, site string, ref string) (*driver.Reservation, error) {
+	reservation, err := registry.Find(site, ref)
+	if err != nil {
+		return nil, err
+	}
+	if reservation == nil {
+		return nil, fmt.Errorf("There is no reservation named '%s' or with this job ID in the registry", ref)
+	}
+
+	return reservation, nil
+}
+
+// listReservations print the reservations of the registry
+func listReservations(flags *flag.FlagSet, args []string) error {
+	site := flags.String("site", os.Getenv("G5K_SITE"), "Only list the reservations of the site (all the sites if empty)")
+	format := addFormatFlag(flags)
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	reservations, err := driver.NewReservationRegistry(getStorePath()).Load()
+	if err != nil {
+		return err
+	}
+
+	listed := []reservationDetails{}
+	for _, reservation := range reservations {
+		if *site == "" || reservation.Site == *site {
+			listed = append(listed, reservationDetails{Reservation: reservation, State: reservation.State()})
+		}
+	}
+
+	return printResult(*format, listed, func(table *tabwriter.Writer) {
+		fmt.Fprintln(table, "NAME\tSITE\tJOB ID\tSTART TIME\tWALLTIME\tSTATE\tMACHINES")
+		for _, reservation := range listed {
+			fmt.Fprintf(table, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", reservation.Name, reservation.Site, reservation.JobID, reservation.StartTime, reservation.Walltime, reservation.State, strings.Join(reservation.Machines, ","))
+		}
+	})
+}
+
+// inspectReservation print a reservation of the registry with the current state of its job
+func inspectReservation(flags *flag.FlagSet, args []string) error {
+	site := flags.String("site", os.Getenv("G5K_SITE"), "Site of the reservation (any site if empty)")
+	format := addFormatFlag(flags)
+	args, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	registry := driver.NewReservationRegistry(getStorePath())
+	reservation, err := findReservation(registry, *site, args[0])
+	if err != nil {
+		return err
+	}
+
+	details := reservationDetails{Reservation: *reservation, State: reservation.State()}
+
+	// the current state of the job is only available with the credentials
+	if client, err := newAPIClient(reservation.Site); err != nil {
+		fmt.Fprintf(stderr, "Warning: the state of the job is not available: %s\n", err)
+	} else if job, err := client.GetJob(reservation.JobID); err != nil {
+		fmt.Fprintf(stderr, "Warning: the state of the job is not available: %s\n", err)
+	} else {
+		details.JobState = job.State
+
+		// record the nodes of the job when they are assigned
+		if len(job.Nodes) > 0 {
+			details.Nodes = job.Nodes
+			if _, err := registry.Update(reservation.Site, strconv.Itoa(reservation.JobID), func(r *driver.Reservation) { r.Nodes = job.Nodes }); err != nil {
+				fmt.Fprintf(stderr, "Warning: %s\n", err)
+			}
+		}
+	}
+
+	return printResult(*format, details, func(table *tabwriter.Writer) {
+		writeFields(table, [][2]string{
+			{"Name", details.Name},
+			{"Site", details.Site},
+			{"Job ID", strconv.Itoa(details.JobID)},
+			{"Start time", details.StartTime},
+			{"Walltime", details.Walltime},
+			{"Queue", details.Queue},
+			{"Project", details.Project},
+			{"Properties", details.Properties},
+			{"Reference environment reuse", strconv.FormatBool(details.ReuseRefEnvironment)},
+			{"Nodes", strings.Join(details.Nodes, ",")},
+			{"Machines", strings.Join(details.Machines, ",")},
+			{"State", details.State},
+			{"Job state", details.JobState},
+			{"Created at", driver.FormatReservationDate(details.CreatedAt)},
+		})
+	})
+}
+
+// cancelReservation kill the job of a reservation of the registry and mark it as cancelled
+func cancelReservation(flags *flag.FlagSet, args []string) error {
+	site := flags.String("site", os.Getenv("G5K_SITE"), "Site of the reservation (any site if empty)")
+	args, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	registry := driver.NewReservationRegistry(getStorePath())
+	reservation, err := findReservation(registry, *site, args[0])
+	if err != nil {
+		return err
+	}
+
+	client, err := newAPIClient(reservation.Site)
+	if err != nil {
+		return err
+	}
+
+	if err := client.KillJob(reservation.JobID); err != nil {
+		return err
+	}
+
+	if _, err := registry.Update(reservation.Site, strconv.Itoa(reservation.JobID), func(r *driver.Reservation) {
+		r.Cancelled = true
+		r.Pending = false
+	}); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "The reservation '%s' (job id: %d) has been cancelled\n", reservation.Name, reservation.JobID)
+	return nil
+}
@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"net"
 	"net/url"
-
-	"github.com/docker/machine/libmachine/mcnutils"
+	"os"
+	"time"
 
 	"github.com/Spirals-Team/docker-machine-driver-g5k/api"
 
@@ -16,9 +16,6 @@ import (
 	gossh "golang.org/x/crypto/ssh"
 )
 
-// g5kReferenceEnvironment is the name of the reference environment automatically deployed on the node by Grid'5000
-const g5kReferenceEnvironmentName string = "debian11-std"
-
 // Driver parameters
 type Driver struct {
 	*drivers.BaseDriver
@@ -39,9 +36,32 @@ type Driver struct {
 	G5kKeepAllocatedResourceAtDeletion bool
 	G5kNodeHostname                    string
 	G5kJobTypes                        []string
+	G5kImageArchive                    string
+	G5kImageDescription                string
+	G5kFrontendSSHKeyPath              string
+	G5kUserDataPath                    string
+	G5kRecreateOnReboot                bool
+	G5kPowerStateCacheTTL              int
+	G5kBesteffortResubmit              bool
+	G5kNodeSetupPending                bool
+	G5kAPIDebug                        bool
+	G5kAPITraceFile                    string
+	G5kDryRun                          bool
+	G5kNodes                           int
+	G5kGPUs                            int
+	G5kTopology                        string
+	G5kSubnets                         string
+	G5kIgnoreUsagePolicy               bool
+	G5kReservationMode                 string
+	G5kPendingReservation              string
+	G5kProject                         string
+	G5kJobSubmittedByDriver            bool
+	G5kJobOwnershipRecorded            bool
+	G5kKeepOnFailure                   bool
 
 	// Ephemeral fields
-	g5kAPI *api.Client
+	g5kAPI        *api.Client
+	pendingEvents []driverEvent
 }
 
 // NewDriver creates and returns a new instance of the driver
@@ -86,15 +106,15 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 		mcnflag.StringFlag{
 			EnvVar: "G5K_WALLTIME",
 			Name:   "g5k-walltime",
-			Usage:  "Machine's lifetime (HH:MM:SS)",
+			Usage:  "Machine's lifetime (in 'HH:MM:SS' format or as a duration, ex: '90m', '1d12h')",
 			Value:  "1:00:00",
 		},
 
 		mcnflag.StringFlag{
 			EnvVar: "G5K_IMAGE",
 			Name:   "g5k-image",
-			Usage:  "Name of the image (environment) to deploy on the node",
-			Value:  g5kReferenceEnvironmentName,
+			Usage:  "Name of the image (environment) to deploy on the node (default to the reference environment of the site)",
+			Value:  "",
 		},
 
 		mcnflag.StringFlag{
@@ -112,20 +132,33 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 		mcnflag.StringFlag{
 			EnvVar: "G5K_JOB_QUEUE",
 			Name:   "g5k-job-queue",
-			Usage:  "Specify the job queue (besteffort is NOT supported)",
+			Usage:  "Specify the job queue",
 			Value:  "default",
 		},
 
 		mcnflag.StringFlag{
 			EnvVar: "G5K_MAKE_RESOURCE_RESERVATION",
 			Name:   "g5k-make-resource-reservation",
-			Usage:  "Make a resource reservation for the given start date. (in either 'YYYY-MM-DD HH:MM:SS' date format or an UNIX timestamp)",
+			Usage:  "Make a resource reservation for the given start date. (in RFC3339 or 'YYYY-MM-DD HH:MM:SS' (Europe/Paris) date format, an UNIX timestamp, a relative duration like '+2h' or 'tonight')",
+		},
+
+		mcnflag.StringFlag{
+			EnvVar: "G5K_RESERVATION_MODE",
+			Name:   "g5k-reservation-mode",
+			Usage:  "What to do after making a resource reservation: 'abort' the machine creation, 'wait' for the reservation to start to create the machine, or 'record' it as a pending reservation",
+			Value:  reservationModeAbort,
+		},
+
+		mcnflag.StringFlag{
+			EnvVar: "G5K_USE_PENDING_RESERVATION",
+			Name:   "g5k-use-pending-reservation",
+			Usage:  "Use the pending reservation recorded with the given name (the name of the machine which made the reservation)",
 		},
 
 		mcnflag.IntFlag{
 			EnvVar: "G5K_USE_RESOURCE_RESERVATION",
 			Name:   "g5k-use-resource-reservation",
-			Usage:  "Use a resource reservation (need to be a job of 'deploy' type and in the 'running' state)",
+			Usage:  "Use a resource reservation (need to be a job of 'deploy' type, or made by the driver when reusing the reference environment)",
 		},
 
 		mcnflag.StringFlag{
@@ -151,6 +184,110 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:   "g5k-job-types",
 			Usage:  "Specify the job type(s)",
 		},
+
+		mcnflag.StringFlag{
+			EnvVar: "G5K_IMAGE_ARCHIVE",
+			Name:   "g5k-image-archive",
+			Usage:  "Local image archive of a custom environment to deploy on the node (requires an environment description)",
+		},
+
+		mcnflag.StringFlag{
+			EnvVar: "G5K_IMAGE_DESCRIPTION",
+			Name:   "g5k-image-description",
+			Usage:  "Local environment description (kaenv YAML format) of the custom environment to deploy on the node",
+		},
+
+		mcnflag.StringFlag{
+			EnvVar: "G5K_FRONTEND_SSH_KEY",
+			Name:   "g5k-frontend-ssh-key",
+			Usage:  "SSH private key of your Grid5000 account used to connect to the site frontend (the SSH agent is used by default)",
+		},
+
+		mcnflag.StringFlag{
+			EnvVar: "G5K_USER_DATA",
+			Name:   "g5k-user-data",
+			Usage:  "User-data file (cloud-config or shell script) applied on the node before provisioning Docker",
+		},
+
+		mcnflag.BoolFlag{
+			EnvVar: "G5K_RECREATE_ON_REBOOT",
+			Name:   "g5k-recreate-on-reboot",
+			Usage:  "Submit a new job when starting a machine whose node rebooted while reusing the reference environment",
+		},
+
+		mcnflag.IntFlag{
+			EnvVar: "G5K_POWER_STATE_CACHE_TTL",
+			Name:   "g5k-power-state-cache-ttl",
+			Usage:  "Duration (in seconds) during which the power state of the node is cached (querying the power state takes some time)",
+			Value:  g5kDefaultPowerStateCacheTTL,
+		},
+
+		mcnflag.BoolFlag{
+			EnvVar: "G5K_BESTEFFORT_RESUBMIT",
+			Name:   "g5k-besteffort-resubmit",
+			Usage:  "Automatically submit another job when the new besteffort job submitted by 'start' is preempted before its node is set up",
+		},
+
+		mcnflag.BoolFlag{
+			EnvVar: "G5K_API_DEBUG",
+			Name:   "g5k-api-debug",
+			Usage:  "Log the requests sent to the Grid5000 API and their responses (without the credentials)",
+		},
+
+		mcnflag.StringFlag{
+			EnvVar: "G5K_API_TRACE_FILE",
+			Name:   "g5k-api-trace-file",
+			Usage:  "File where the requests sent to the Grid5000 API and their responses are saved (in HAR format)",
+		},
+
+		mcnflag.IntFlag{
+			EnvVar: "G5K_NODES",
+			Name:   "g5k-nodes",
+			Usage:  "Number of nodes reserved by the job (the machine uses the first one)",
+			Value:  1,
+		},
+
+		mcnflag.IntFlag{
+			EnvVar: "G5K_GPUS",
+			Name:   "g5k-gpus",
+			Usage:  "Minimum number of GPUs of the reserved nodes",
+		},
+
+		mcnflag.StringFlag{
+			EnvVar: "G5K_TOPOLOGY",
+			Name:   "g5k-topology",
+			Usage:  "Topology constraint of the reserved nodes, as an OAR resource hierarchy (ex: 'cluster=1/switch=1')",
+		},
+
+		mcnflag.StringFlag{
+			EnvVar: "G5K_SUBNETS",
+			Name:   "g5k-subnets",
+			Usage:  "IP subnets reserved with the nodes, as OAR subnet resources (ex: 'slash_22=1')",
+		},
+
+		mcnflag.BoolFlag{
+			EnvVar: "G5K_IGNORE_USAGE_POLICY",
+			Name:   "g5k-ignore-usage-policy",
+			Usage:  "Only warn about the violations of the Grid5000 usage policy instead of refusing to submit the job",
+		},
+
+		mcnflag.StringFlag{
+			EnvVar: "G5K_PROJECT",
+			Name:   "g5k-project",
+			Usage:  "Project of the job, used to attribute the usage of the resources",
+		},
+
+		mcnflag.BoolFlag{
+			EnvVar: "G5K_KEEP_ON_FAILURE",
+			Name:   "g5k-keep-on-failure",
+			Usage:  "Keep the job submitted by the driver when the machine creation fails (for debugging)",
+		},
+
+		mcnflag.BoolFlag{
+			EnvVar: "G5K_DRY_RUN",
+			Name:   "g5k-dry-run",
+			Usage:  "Print and check the job and deployment requests without submitting them, the machine creation is aborted",
+		},
 	}
 }
 
@@ -167,10 +304,29 @@ func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
 	d.G5kJobQueue = opts.String("g5k-job-queue")
 	d.G5kJobStartTime = opts.String("g5k-make-resource-reservation")
 	d.G5kJobID = opts.Int("g5k-use-resource-reservation")
+	d.G5kReservationMode = opts.String("g5k-reservation-mode")
+	d.G5kPendingReservation = opts.String("g5k-use-pending-reservation")
 	d.ExternalSSHPublicKeys = opts.StringSlice("g5k-external-ssh-public-keys")
 	d.G5kKeepAllocatedResourceAtDeletion = opts.Bool("g5k-keep-resource-at-deletion")
 	d.G5kNodeHostname = opts.String("g5k-select-node-from-reservation")
 	d.G5kJobTypes = opts.StringSlice("g5k-job-types")
+	d.G5kImageArchive = opts.String("g5k-image-archive")
+	d.G5kImageDescription = opts.String("g5k-image-description")
+	d.G5kFrontendSSHKeyPath = opts.String("g5k-frontend-ssh-key")
+	d.G5kUserDataPath = opts.String("g5k-user-data")
+	d.G5kRecreateOnReboot = opts.Bool("g5k-recreate-on-reboot")
+	d.G5kPowerStateCacheTTL = opts.Int("g5k-power-state-cache-ttl")
+	d.G5kBesteffortResubmit = opts.Bool("g5k-besteffort-resubmit")
+	d.G5kAPIDebug = opts.Bool("g5k-api-debug")
+	d.G5kAPITraceFile = opts.String("g5k-api-trace-file")
+	d.G5kDryRun = opts.Bool("g5k-dry-run")
+	d.G5kNodes = opts.Int("g5k-nodes")
+	d.G5kGPUs = opts.Int("g5k-gpus")
+	d.G5kTopology = opts.String("g5k-topology")
+	d.G5kSubnets = opts.String("g5k-subnets")
+	d.G5kIgnoreUsagePolicy = opts.Bool("g5k-ignore-usage-policy")
+	d.G5kProject = opts.String("g5k-project")
+	d.G5kKeepOnFailure = opts.Bool("g5k-keep-on-failure")
 
 	if d.G5kUsername == "" {
 		return fmt.Errorf("You must give your Grid5000 account username")
@@ -182,20 +338,110 @@ func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
 		return fmt.Errorf("You must give the site you want to reserve the resources on")
 	}
 
-	// The besteffort queue is only for interruptible jobs and cannot be used in the case of Docker machine
+	// normalize the walltime and the reservation date to the formats expected by OAR
+	walltime, err := ParseWalltime(d.G5kWalltime)
+	if err != nil {
+		return err
+	}
+	d.G5kWalltime = FormatWalltime(walltime)
+
+	if d.G5kJobStartTime != "" {
+		startTime, err := ParseReservationDate(d.G5kJobStartTime, time.Now())
+		if err != nil {
+			return err
+		}
+		d.G5kJobStartTime = FormatReservationDate(startTime)
+	}
+
 	if d.G5kJobQueue == "besteffort" {
-		return fmt.Errorf("The besteffort queue is not supported")
+		// OAR does not allow advance reservations in the besteffort queue
+		if d.G5kJobStartTime != "" {
+			return fmt.Errorf("Making a resource reservation in the besteffort queue is not possible")
+		}
+
+		// OAR would resubmit the preempted idempotent jobs, in addition to the jobs submitted by the driver when starting the machine
+		if ArrayContainsString(d.G5kJobTypes, "idempotent") {
+			return fmt.Errorf("The 'idempotent' job type is not supported, the driver submits a new job when starting a machine whose job has been preempted")
+		}
+	} else if d.G5kBesteffortResubmit {
+		return fmt.Errorf("Resubmitting the preempted jobs is only possible in the besteffort queue")
+	}
+
+	if d.G5kReservationMode != reservationModeAbort && d.G5kReservationMode != reservationModeWait && d.G5kReservationMode != reservationModeRecord {
+		return fmt.Errorf("The reservation mode must be either '%s', '%s' or '%s'", reservationModeAbort, reservationModeWait, reservationModeRecord)
+	}
+
+	if err := d.prepareDriverStoreDirectory(); err != nil {
+		return err
+	}
+
+	if d.G5kPendingReservation != "" {
+		// Contradictory use of parameters: using a pending reservation while making or using another reservation
+		if d.G5kJobID != 0 || d.G5kJobStartTime != "" {
+			return fmt.Errorf("You have to choose between using a pending reservation or making/using another reservation")
+		}
+
+		if err := d.usePendingReservation(d.G5kPendingReservation); err != nil {
+			return err
+		}
+	}
+
+	// the machines created by the previous versions of the driver did not record whether their job was submitted by the driver
+	d.G5kJobOwnershipRecorded = true
+
+	if d.g5kAPI, err = d.newAPIClient(); err != nil {
+		return err
+	}
+
+	// the reference environment changes with each new Debian release, it needs to be resolved for the site
+	// it is only needed when it is reused or deployed, so the API is not queried when another image is given
+	refEnvName := ""
+	if d.G5kReuseRefEnvironment || (d.G5kImage == "" && d.G5kImageArchive == "") {
+		if refEnvName, err = d.resolveReferenceEnvironment(); err != nil {
+			return err
+		}
 	}
 
 	if d.G5kReuseRefEnvironment {
 		// Contradictory use of parameters: providing an image to deploy while trying to reuse the reference environment
-		if d.G5kImage != g5kReferenceEnvironmentName {
+		if d.G5kImage != "" && d.G5kImage != refEnvName {
 			return fmt.Errorf("You have to choose between reusing the reference environment or redeploying the node with another image")
 		}
+	}
+
+	if d.G5kImageArchive != "" || d.G5kImageDescription != "" {
+		// A custom environment needs both the image archive and its description
+		if d.G5kImageArchive == "" || d.G5kImageDescription == "" {
+			return fmt.Errorf("You must give both the image archive and the environment description to deploy a custom environment")
+		}
+
+		// Contradictory use of parameters: providing an image to deploy while trying to deploy a custom environment
+		if d.G5kImage != "" || d.G5kReuseRefEnvironment {
+			return fmt.Errorf("You have to choose between deploying a custom environment or using another image")
+		}
+
+		if err := d.checkCustomEnvironment(); err != nil {
+			return err
+		}
+	}
+
+	// deploy the reference environment when no image is provided
+	if d.G5kImage == "" && d.G5kImageArchive == "" {
+		d.G5kImage = refEnvName
+	}
+
+	if d.G5kPowerStateCacheTTL < 0 {
+		return fmt.Errorf("The power state cache TTL must be positive")
+	}
 
-		// Reusing the reference environment is only possible when the job is NOT of type 'deploy'
-		if d.G5kJobStartTime != "" || d.G5kJobID != 0 {
-			return fmt.Errorf("Reusing the Grid'5000 reference environment on a resource reservation is not supported")
+	// Recreating the job is only needed when reusing the reference environment
+	if d.G5kRecreateOnReboot && !d.G5kReuseRefEnvironment {
+		return fmt.Errorf("Recreating the job on reboot is only possible when reusing the reference environment")
+	}
+
+	if d.G5kUserDataPath != "" {
+		if err := d.checkUserData(); err != nil {
+			return err
 		}
 	}
 
@@ -206,11 +452,37 @@ func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
 		}
 	}
 
+	if d.G5kRecreateOnReboot && d.G5kJobID != 0 {
+		// the jobs given by the user are never replaced by the driver
+		return fmt.Errorf("Recreating the job on reboot is not possible when using a resource reservation")
+	}
+
 	if len(d.G5kJobTypes) > 0 && d.G5kJobID != 0 {
 		// Incorrect use of the job type(s) flag with an existing resource reservation
 		return fmt.Errorf("Setting the job type(s) is not possible when using a resource reservation, this have to be set when making the reservation")
 	}
 
+	if d.G5kProject != "" && d.G5kJobID != 0 {
+		// Incorrect use of the project flag with an existing resource reservation
+		return fmt.Errorf("Setting the project is not possible when using a resource reservation, this have to be set when making the reservation")
+	}
+
+	if d.G5kNodes < 1 || d.G5kGPUs < 0 {
+		return fmt.Errorf("The number of nodes must be at least 1 and the number of GPUs must be positive")
+	}
+
+	if d.G5kJobID != 0 {
+		// Incorrect use of the resources flags with an existing resource reservation
+		if d.G5kNodes != 1 || d.G5kGPUs != 0 || d.G5kTopology != "" || d.G5kSubnets != "" {
+			return fmt.Errorf("Setting the resources is not possible when using a resource reservation, this have to be set when making the reservation")
+		}
+	} else {
+		// check the resources before submitting the job
+		if _, err := d.newResourceRequest(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -218,9 +490,11 @@ func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
 func (d *Driver) GetIP() (string, error) {
 	if d.IPAddress == "" {
 		if d.G5kNodeHostname == "" {
-			d.g5kAPI = api.NewClient(d.G5kUsername, d.G5kPassword, d.G5kSite)
+			if err := d.initAPIClient(); err != nil {
+				return "", err
+			}
 
-			job, err := d.g5kAPI.GetJob(d.G5kJobID)
+			job, err := d.getCachedJob()
 			if err != nil {
 				return "", err
 			}
@@ -284,9 +558,11 @@ func (d *Driver) GetURL() (string, error) {
 
 // GetState returns the state that the host is in (running, stopped, etc)
 func (d *Driver) GetState() (state.State, error) {
-	d.g5kAPI = api.NewClient(d.G5kUsername, d.G5kPassword, d.G5kSite)
+	if err := d.initAPIClient(); err != nil {
+		return state.None, err
+	}
 
-	job, err := d.g5kAPI.GetJob(d.G5kJobID)
+	job, err := d.getCachedJob()
 	if err != nil {
 		return state.None, err
 	}
@@ -299,37 +575,89 @@ func (d *Driver) GetState() (state.State, error) {
 		return state.Starting, nil
 	case "hold":
 		return state.Stopped, nil
-	case "error":
-		return state.Error, nil
-	case "terminated":
+	case "error", "terminated":
+		// a besteffort job is killed by OAR when its resources are needed by a regular job
+		// GetState only reports it, the new job is submitted when starting the machine
+		if getJobTerminationCause(job) == jobTerminationPreempted {
+			if err := d.checkJobReplacement(job); err != nil {
+				return state.Error, err
+			}
+			return state.Error, fmt.Errorf("The besteffort job (id: %d) has been preempted by OAR, start the machine to submit a new job", d.G5kJobID)
+		}
+
+		// in the reference environment, a reboot of the node terminates the job
+		if d.G5kReuseRefEnvironment && getJobTerminationCause(job) == jobTerminationNodeReboot {
+			if err := d.checkJobReplacement(job); err != nil {
+				return state.Error, err
+			}
+			return state.Error, fmt.Errorf("The job (id: %d) has been terminated because the node rebooted, start the machine to submit a new job", d.G5kJobID)
+		}
+
+		if job.State == "error" {
+			return state.Error, nil
+		}
 		return state.Stopped, nil
 	case "running":
-		// noop, needs further checks
+		// the node of a replacement job is set up when starting the machine
+		if d.G5kNodeSetupPending {
+			return state.Stopped, nil
+		}
 	default:
 		return state.None, fmt.Errorf("The job is in an unexpected state: %s", job.State)
 	}
 
-	// Try to connect to the site frontend ssh server before continuing.
-	// This prevent to wrongly report the machine as Stopped when the user is disconnected from the VPN.
-	if err := d.checkVpnConfiguration(); err != nil {
+	ip, err := d.GetIP()
+	if err != nil {
 		return state.None, err
 	}
 
-	ip, err := d.GetIP()
-	if err != nil {
+	// Try to connect to the site frontend ssh server and to the node ssh server concurrently.
+	// The frontend check prevent to wrongly report the machine as Stopped when the user is disconnected from the VPN.
+	vpnCheck := make(chan error, 1)
+	go func() {
+		vpnCheck <- d.checkCachedVpnConfiguration()
+	}()
+
+	nodeErr := CheckSSHConnection(ip)
+	if err := <-vpnCheck; err != nil {
+		return state.None, err
+	}
+
+	if nodeErr == nil {
+		d.logEvent(driverEvent{Type: eventSSHCheck, Node: ip, State: "reachable"})
+		return state.Running, nil
+	}
+
+	// the cached VPN check can be outdated, the VPN connection is checked again before reporting the node as unreachable
+	if err := d.checkVpnConfiguration(); err != nil {
 		return state.None, err
 	}
 
-	// Try to connect to the node ssh server
-	if err := CheckSSHConnection(ip); err != nil {
+	d.logEvent(driverEvent{Type: eventSSHCheck, Node: ip, State: "unreachable"})
+
+	// the power state of the node cannot be requested when reusing the reference environment
+	if d.G5kReuseRefEnvironment {
+		return state.Stopped, nil
+	}
+
+	// the node is unreachable, its BMC tells if it is powered off or still booting
+	powerState, err := d.getCachedNodePowerState()
+	if err != nil {
+		log.Debugf("Failed to get the power state of the node: %s", err.Error())
 		return state.Stopped, nil
 	}
 
-	return state.Running, nil
+	if powerState == "on" {
+		return state.Starting, nil
+	}
+
+	return state.Stopped, nil
 }
 
 // PreCreateCheck check parameters and submit the job to Grid5000
-func (d *Driver) PreCreateCheck() error {
+func (d *Driver) PreCreateCheck() (err error) {
+	defer d.logErrorEvent("pre-create", &err)
+
 	if err := d.prepareDriverStoreDirectory(); err != nil {
 		return err
 	}
@@ -339,7 +667,9 @@ func (d *Driver) PreCreateCheck() error {
 		return err
 	}
 
-	d.g5kAPI = api.NewClient(d.G5kUsername, d.G5kPassword, d.G5kSite)
+	if err := d.initAPIClient(); err != nil {
+		return err
+	}
 
 	if err := d.loadDriverSSHPublicKey(); err != nil {
 		return err
@@ -353,6 +683,18 @@ func (d *Driver) PreCreateCheck() error {
 		}
 	}
 
+	// check the job against the usage policy before submitting it
+	if d.G5kJobID == 0 {
+		if err := d.checkJobUsagePolicy(); err != nil {
+			return err
+		}
+	}
+
+	// print and check the requests instead of submitting them
+	if d.G5kDryRun {
+		return d.dryRunCreation()
+	}
+
 	// skip the job submission/reservation if a job ID is provided
 	if d.G5kJobID == 0 {
 		if d.G5kJobStartTime == "" {
@@ -361,22 +703,61 @@ func (d *Driver) PreCreateCheck() error {
 				return err
 			}
 		} else {
+			// the pending reservations are recorded under the name of the machine
+			if d.G5kReservationMode == reservationModeRecord {
+				if reservation, err := d.findPendingReservation(d.GetMachineName()); err != nil {
+					return err
+				} else if reservation != nil {
+					return fmt.Errorf("A pending reservation named '%s' already exists (job id: %d)", reservation.Name, reservation.JobID)
+				}
+			}
+
 			// make a job reservation: the resources will be reserved for a defined date/time
 			if err := d.makeJobReservation(); err != nil {
 				return err
 			}
 
-			// stop the machine creation
-			return fmt.Errorf("The job reservation have been successfully sent. Don't forget to save the Job ID to create the machine when the resources are available")
+			// the reservations made through the driver are kept in the registry
+			if err := d.recordReservation(d.G5kReservationMode == reservationModeRecord); err != nil {
+				if d.G5kReservationMode == reservationModeRecord {
+					// the reservation cannot be used without its record
+					d.rollbackJob()
+					return err
+				}
+				log.Warnf("Failed to record the reservation in the registry: %s", err)
+			}
+
+			switch d.G5kReservationMode {
+			case reservationModeWait:
+				// the machine creation waits for the job to be running
+				log.Infof("Waiting for the reservation to start to create the machine...")
+			case reservationModeRecord:
+				// stop the machine creation
+				return fmt.Errorf("The job reservation (id: %d) have been recorded as the '%s' pending reservation. Create the machine with the '--g5k-use-pending-reservation %s' flag when the resources are available", d.G5kJobID, d.GetMachineName(), d.GetMachineName())
+			default:
+				// stop the machine creation
+				return fmt.Errorf("The job reservation have been successfully sent. Don't forget to save the Job ID to create the machine when the resources are available")
+			}
 		}
 	}
 
 	return nil
 }
 
-// Create wait for the job to be running, deploy the OS image and copy the ssh keys
-func (d *Driver) Create() error {
-	d.g5kAPI = api.NewClient(d.G5kUsername, d.G5kPassword, d.G5kSite)
+// Create wait for the job to be running, deploy the OS image, copy the ssh keys and apply the user-data
+func (d *Driver) Create() (err error) {
+	defer d.logErrorEvent("create", &err)
+
+	if err := d.initAPIClient(); err != nil {
+		return err
+	}
+
+	// the job submitted by the driver is not needed anymore if the machine creation fails
+	defer func() {
+		if err != nil {
+			d.rollbackJob()
+		}
+	}()
 
 	// wait for job to be in 'running' state
 	if err := d.waitUntilJobIsReady(); err != nil {
@@ -388,53 +769,147 @@ func (d *Driver) Create() error {
 	}
 
 	// copy driver SSH key pair to machine directory
-	if err := mcnutils.CopyFile(d.getDriverSSHKeyPath(), d.GetSSHKeyPath()); err != nil {
+	if err := d.installDriverSSHKey(); err != nil {
+		return err
+	}
+
+	// the node can still be booting, and the job command can still be authorizing the driver key when reusing the reference environment
+	if err := d.waitUntilNodeIsReachable(g5kNodeBootTimeout); err != nil {
 		return err
 	}
-	if err := mcnutils.CopyFile(d.getDriverSSHKeyPath()+".pub", d.GetSSHKeyPath()+".pub"); err != nil {
+
+	// apply the user-data before the provisioning of Docker
+	if err := d.applyUserData(); err != nil {
 		return err
 	}
 
+	// the reservation is used by the machine, it is no longer pending
+	if err := d.registerReservationMachine(); err != nil {
+		log.Warnf("Failed to record the machine in the reservations registry: %s", err)
+	}
+
 	return nil
 }
 
 // Remove delete the resources reservation
-func (d *Driver) Remove() error {
-	d.g5kAPI = api.NewClient(d.G5kUsername, d.G5kPassword, d.G5kSite)
+func (d *Driver) Remove() (err error) {
+	defer d.logErrorEvent("remove", &err)
+
+	if err := d.initAPIClient(); err != nil {
+		return err
+	}
 
 	// keep the resource allocated if the user asked for it
 	if !d.G5kKeepAllocatedResourceAtDeletion {
 		log.Infof("Deallocating resource... (Job ID: '%d')", d.G5kJobID)
-		return d.g5kAPI.KillJob(d.G5kJobID)
+		if err := d.g5kAPI.KillJob(d.G5kJobID); err != nil {
+			return err
+		}
+
+		d.logEvent(driverEvent{Type: eventJobKilled})
 	}
 
+	d.removeCachedJob()
+
 	return nil
 }
 
 // Kill perform a hard power-off on the node
-func (d *Driver) Kill() error {
-	d.g5kAPI = api.NewClient(d.G5kUsername, d.G5kPassword, d.G5kSite)
+func (d *Driver) Kill() (err error) {
+	defer d.logErrorEvent("kill", &err)
+
+	if err := d.initAPIClient(); err != nil {
+		return err
+	}
 
-	return d.changeNodePowerStatus("off", "hard")
+	job, err := d.g5kAPI.GetJob(d.G5kJobID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkJobIsRunning(job); err != nil {
+		return err
+	}
+
+	return d.setNodePowerStatus("off", "hard")
 }
 
-// Start perform a soft power-on on the node
-func (d *Driver) Start() error {
-	d.g5kAPI = api.NewClient(d.G5kUsername, d.G5kPassword, d.G5kSite)
+// Start perform a soft power-on on the node, or submit a new job if the job is no longer running
+func (d *Driver) Start() (err error) {
+	defer d.logErrorEvent("start", &err)
+
+	if err := d.initAPIClient(); err != nil {
+		return err
+	}
+
+	job, err := d.g5kAPI.GetJob(d.G5kJobID)
+	if err != nil {
+		return err
+	}
+
+	// the node is not allocated anymore, a new job is needed
+	if job.State == "error" || job.State == "terminated" {
+		if err := d.checkJobReplacement(job); err != nil {
+			return err
+		}
+
+		log.Infof("The job (id: %d) is no longer running (cause: '%s'), submitting a new job...", d.G5kJobID, getJobTerminationCause(job))
+		return d.recreateJob()
+	}
+
+	// the replacement job have been submitted but its node is not set up yet
+	if d.G5kNodeSetupPending {
+		return d.setupReplacementNode()
+	}
+
+	if err := checkJobIsRunning(job); err != nil {
+		return err
+	}
 
-	return d.changeNodePowerStatus("on", "soft")
+	return d.setNodePowerStatus("on", "soft")
 }
 
 // Stop perform a soft power-off on the node
-func (d *Driver) Stop() error {
-	d.g5kAPI = api.NewClient(d.G5kUsername, d.G5kPassword, d.G5kSite)
+func (d *Driver) Stop() (err error) {
+	defer d.logErrorEvent("stop", &err)
+
+	if err := d.initAPIClient(); err != nil {
+		return err
+	}
 
-	return d.changeNodePowerStatus("off", "soft")
+	job, err := d.g5kAPI.GetJob(d.G5kJobID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkJobIsRunning(job); err != nil {
+		return err
+	}
+
+	return d.setNodePowerStatus("off", "soft")
 }
 
-// Restart perform a soft reboot on the node
-func (d *Driver) Restart() error {
-	d.g5kAPI = api.NewClient(d.G5kUsername, d.G5kPassword, d.G5kSite)
+// Restart perform a reboot on the node, the G5K_RESTART_MODE environment variable allows to select the kind of reboot
+func (d *Driver) Restart() (err error) {
+	defer d.logErrorEvent("restart", &err)
+
+	if err := d.initAPIClient(); err != nil {
+		return err
+	}
 
-	return d.rebootNode("soft")
+	switch mode := os.Getenv("G5K_RESTART_MODE"); mode {
+	case "", "soft":
+		return d.rebootNodeWithEscalation()
+	case "hard":
+		if err := d.rebootNode("simple", "hard"); err != nil {
+			return err
+		}
+		return d.waitUntilNodeIsReachable(g5kNodeBootTimeout)
+	case "set_pxe", "recorded_env", "deploy_env":
+		return d.rebootNode(mode, "soft")
+	case "redeploy":
+		return d.redeployNode()
+	default:
+		return fmt.Errorf("The restart mode '%s' is not supported (supported modes: soft, hard, set_pxe, recorded_env, deploy_env, redeploy)", mode)
+	}
 }
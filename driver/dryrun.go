@@ -0,0 +1,186 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Spirals-Team/docker-machine-driver-g5k/api"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// dryRunNodePlaceholder replaces the node of the deployment request when it is not known before the job is running
+const dryRunNodePlaceholder string = "<node allocated to the job>"
+
+// propertiesClusterRegexp matches the clusters selected by the resource properties (ex: "cluster='ecotype'")
+var propertiesClusterRegexp = regexp.MustCompile(`\bcluster\s*=\s*'([^']*)'`)
+
+// logDryRunRequest log the given request in JSON format
+func logDryRunRequest(name string, request interface{}) {
+	data, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		log.Infof("%s: %+v", name, request)
+		return
+	}
+
+	log.Infof("%s:\n%s", name, data)
+}
+
+// newDryRunDeploymentRequest returns the deployment request that would be submitted for the machine, nil if the node is not deployed
+func (d *Driver) newDryRunDeploymentRequest() (*api.DeploymentRequest, error) {
+	if d.G5kReuseRefEnvironment {
+		return nil, nil
+	}
+
+	node := d.G5kNodeHostname
+	if node == "" {
+		node = dryRunNodePlaceholder
+	}
+
+	deploymentRequest := d.newDeploymentRequest(node)
+
+	// the custom environment is not staged, but the URL of its staged description is known in advance
+	if d.G5kImageArchive != "" {
+		env, err := d.prepareCustomEnvironment()
+		if err != nil {
+			return nil, err
+		}
+
+		deploymentRequest.Environment = d.getStagedFileURL(env.descriptionFilename)
+	}
+
+	return &deploymentRequest, nil
+}
+
+// checkDryRunJob check that the existing job can be used for the machine
+func (d *Driver) checkDryRunJob() []string {
+	job, err := d.g5kAPI.GetJob(d.G5kJobID)
+	if err != nil {
+		return []string{fmt.Sprintf("The job (id: %d) cannot be retrieved: %s", d.G5kJobID, err)}
+	}
+
+	problems := []string{}
+	if job.State == "error" || job.State == "terminated" {
+		problems = append(problems, fmt.Sprintf("The job (id: %d) is in '%s' state", d.G5kJobID, job.State))
+	}
+	if d.G5kReuseRefEnvironment == ArrayContainsString(job.Types, "deploy") {
+		problems = append(problems, fmt.Sprintf("The types of the job (id: %d) are not compatible with the deployment of the node: %s", d.G5kJobID, strings.Join(job.Types, ",")))
+	}
+	if d.G5kNodeHostname != "" && len(job.Nodes) > 0 && !ArrayContainsString(job.Nodes, d.G5kNodeHostname) {
+		problems = append(problems, fmt.Sprintf("The node '%s' is not allocated to the job (id: %d)", d.G5kNodeHostname, d.G5kJobID))
+	}
+
+	return problems
+}
+
+// checkDryRunJobRequest check the queue and the clusters selected by the properties of the job request against the reference API
+func (d *Driver) checkDryRunJobRequest(jobRequest api.JobRequest) []string {
+	clusters, err := d.g5kAPI.GetClusters()
+	if err != nil {
+		return []string{fmt.Sprintf("The clusters of the '%s' site cannot be retrieved: %s", d.G5kSite, err)}
+	}
+
+	queues := []string{}
+	clusterQueues := map[string][]string{}
+	for _, cluster := range clusters {
+		queues = append(queues, cluster.Queues...)
+		clusterQueues[cluster.UID] = cluster.Queues
+	}
+
+	// the besteffort queue is available on every cluster
+	queue := jobRequest.Queue
+	if queue == "" {
+		queue = "default"
+	}
+
+	problems := []string{}
+	if queue != "besteffort" && !ArrayContainsString(queues, queue) {
+		problems = append(problems, fmt.Sprintf("The '%s' queue is not available on the '%s' site", queue, d.G5kSite))
+	}
+
+	for _, matches := range propertiesClusterRegexp.FindAllStringSubmatch(jobRequest.Properties, -1) {
+		cluster := matches[1]
+		if _, ok := clusterQueues[cluster]; !ok {
+			problems = append(problems, fmt.Sprintf("The '%s' cluster selected by the resource properties does not exist on the '%s' site", cluster, d.G5kSite))
+		} else if queue != "besteffort" && !ArrayContainsString(clusterQueues[cluster], queue) {
+			problems = append(problems, fmt.Sprintf("The '%s' cluster selected by the resource properties is not available in the '%s' queue", cluster, queue))
+		}
+	}
+
+	return problems
+}
+
+// checkDryRunImage check that the image of the deployment request is registered in the Kadeploy3 API
+func (d *Driver) checkDryRunImage(image string) []string {
+	// the environments given by the URL of their description are not registered
+	if strings.Contains(image, "://") {
+		return nil
+	}
+
+	// the owner of the environment can be given with the 'name@user' syntax
+	name, owner := splitEnvironmentName(image)
+
+	owners := []string{owner}
+	if owner == "" {
+		owners = []string{g5kReferenceEnvironmentOwner, d.G5kUsername}
+	}
+
+	for _, owner := range owners {
+		environments, err := d.g5kAPI.GetEnvironments(owner)
+		if err != nil {
+			return []string{fmt.Sprintf("The environments of the '%s' user cannot be retrieved: %s", owner, err)}
+		}
+
+		for _, env := range environments {
+			if env.Name == name || env.Alias == name {
+				return nil
+			}
+		}
+	}
+
+	return []string{fmt.Sprintf("The '%s' image is not available on the '%s' site", image, d.G5kSite)}
+}
+
+// dryRunCreation log the requests that would be submitted to create the machine, check them against the API and abort the creation
+func (d *Driver) dryRunCreation() error {
+	problems := []string{}
+
+	if _, err := d.g5kAPI.GetSite(); err != nil {
+		problems = append(problems, fmt.Sprintf("The '%s' site cannot be retrieved: %s", d.G5kSite, err))
+	}
+
+	if d.G5kJobID != 0 {
+		log.Infof("Dry-run: the existing job (id: %d) would be used, no job would be submitted", d.G5kJobID)
+		problems = append(problems, d.checkDryRunJob()...)
+	} else {
+		jobRequest, err := d.newJobRequest()
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			jobRequest.Reservation = d.G5kJobStartTime
+
+			logDryRunRequest("Dry-run: job request", jobRequest)
+			problems = append(problems, d.checkDryRunJobRequest(jobRequest)...)
+		}
+	}
+
+	deploymentRequest, err := d.newDryRunDeploymentRequest()
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if deploymentRequest == nil {
+		log.Infof("Dry-run: the reference environment would be reused, no deployment would be submitted")
+	} else {
+		logDryRunRequest("Dry-run: deployment request", deploymentRequest)
+		if d.G5kImageArchive == "" {
+			problems = append(problems, d.checkDryRunImage(deploymentRequest.Environment)...)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Dry-run: the machine creation would fail, nothing has been submitted:\n- %s", strings.Join(problems, "\n- "))
+	}
+
+	return fmt.Errorf("Dry-run: the requests are valid, nothing has been submitted")
+}
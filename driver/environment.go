@@ -0,0 +1,227 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Spirals-Team/docker-machine-driver-g5k/api"
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+// g5kStagingDirectory is the directory (relative to the user home on the frontend) where the custom environments are staged
+const g5kStagingDirectory string = "public/docker-machine-driver-g5k"
+
+// getStagedFileURL returns the URL of a staged file, served by the public HTTP server of the site
+func (d *Driver) getStagedFileURL(filename string) string {
+	return fmt.Sprintf("http://public.%s.grid5000.fr/~%s/%s/%s", d.G5kSite, d.G5kUsername, strings.TrimPrefix(g5kStagingDirectory, "public/"), url.PathEscape(filename))
+}
+
+// checkCustomEnvironment check that the image archive and the environment description of the custom environment are valid
+func (d *Driver) checkCustomEnvironment() error {
+	if _, err := os.Stat(d.G5kImageArchive); err != nil {
+		return fmt.Errorf("The image archive '%s' is not readable: %s", d.G5kImageArchive, err)
+	}
+
+	description, err := ioutil.ReadFile(d.G5kImageDescription)
+	if err != nil {
+		return fmt.Errorf("The environment description '%s' is not readable: %s", d.G5kImageDescription, err)
+	}
+
+	// the image file will be replaced by the staged archive
+	if _, err := RewriteEnvironmentDescription(description, ""); err != nil {
+		return fmt.Errorf("The environment description '%s' is invalid: %s", d.G5kImageDescription, err)
+	}
+
+	return nil
+}
+
+// RewriteEnvironmentDescription returns the given kaenv environment description with its image file replaced by the given one
+func RewriteEnvironmentDescription(description []byte, imageFile string) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(description, &document); err != nil {
+		return nil, err
+	}
+
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("The description is not a YAML mapping")
+	}
+
+	image := yamlMappingValue(document.Content[0], "image")
+	if image == nil || image.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("The description does not have an 'image' section")
+	}
+
+	file := yamlMappingValue(image, "file")
+	if file == nil || file.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("The description does not have an image file")
+	}
+
+	file.Value = imageFile
+	return yaml.Marshal(&document)
+}
+
+// yamlMappingValue returns the value node of the given key in a YAML mapping node, nil if the key does not exist
+func yamlMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// stageFileToFrontend upload the content to the given path on the frontend, the upload is skipped if an identical file is already staged
+func stageFileToFrontend(client *ssh.Client, content io.Reader, remotePath string, checksum string) error {
+	// reuse the already staged file if its checksum matches
+	out, err := runFrontendCommand(client, fmt.Sprintf("sha256sum %s 2>/dev/null || true", ShellQuote(remotePath)), nil)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(out, checksum+" ") {
+		log.Infof("Reusing the already staged file '%s'", remotePath)
+		return nil
+	}
+
+	log.Infof("Uploading file to '%s' on the frontend...", remotePath)
+
+	// upload to a temporary file to never expose a partially uploaded file
+	tmpPath := fmt.Sprintf("%s.%d.tmp", remotePath, os.Getpid())
+	if _, err := runFrontendCommand(client, fmt.Sprintf("mkdir -p %s && cat > %s", ShellQuote(path.Dir(remotePath)), ShellQuote(tmpPath)), content); err != nil {
+		return err
+	}
+
+	// verify the checksum of the uploaded file
+	out, err = runFrontendCommand(client, fmt.Sprintf("sha256sum %s", ShellQuote(tmpPath)), nil)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(out, checksum+" ") {
+		if _, err := runFrontendCommand(client, fmt.Sprintf("rm -f %s", ShellQuote(tmpPath)), nil); err != nil {
+			log.Warnf("Failed to remove the uploaded file: %s", err)
+		}
+		return fmt.Errorf("The checksum of the uploaded file '%s' does not match (expected: %s)", remotePath, checksum)
+	}
+
+	// the file needs to be readable by the public HTTP server of the site
+	if _, err := runFrontendCommand(client, fmt.Sprintf("chmod 644 %s && mv -f %s %s", ShellQuote(tmpPath), ShellQuote(tmpPath), ShellQuote(remotePath)), nil); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// stagedEnvironment represents the files of a custom environment staged on the frontend
+type stagedEnvironment struct {
+	archiveChecksum     string
+	archiveFilename     string
+	description         []byte
+	descriptionChecksum string
+	descriptionFilename string
+}
+
+// prepareCustomEnvironment compute the names of the staged files of the custom environment and rewrite its description
+func (d *Driver) prepareCustomEnvironment() (*stagedEnvironment, error) {
+	archiveChecksum, err := FileSHA256(d.G5kImageArchive)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to compute the checksum of the image archive: %s", err)
+	}
+
+	description, err := ioutil.ReadFile(d.G5kImageDescription)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the environment description: %s", err)
+	}
+
+	// the staged files are named after their checksum to be reused by other machines
+	archiveFilename := fmt.Sprintf("%s-%s", archiveChecksum, filepath.Base(d.G5kImageArchive))
+	description, err = RewriteEnvironmentDescription(description, d.getStagedFileURL(archiveFilename))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to rewrite the environment description: %s", err)
+	}
+
+	descriptionChecksum := BytesSHA256(description)
+
+	return &stagedEnvironment{
+		archiveChecksum:     archiveChecksum,
+		archiveFilename:     archiveFilename,
+		description:         description,
+		descriptionChecksum: descriptionChecksum,
+		descriptionFilename: fmt.Sprintf("%s.yaml", descriptionChecksum),
+	}, nil
+}
+
+// stageCustomEnvironment upload the image archive and the environment description to the frontend and returns the URL of the staged description
+func (d *Driver) stageCustomEnvironment() (string, error) {
+	env, err := d.prepareCustomEnvironment()
+	if err != nil {
+		return "", err
+	}
+
+	client, err := d.dialFrontend()
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	archive, err := os.Open(d.G5kImageArchive)
+	if err != nil {
+		return "", fmt.Errorf("Failed to open the image archive: %s", err)
+	}
+	defer archive.Close()
+
+	if err := stageFileToFrontend(client, archive, path.Join(g5kStagingDirectory, env.archiveFilename), env.archiveChecksum); err != nil {
+		return "", fmt.Errorf("Failed to stage the image archive: %s", err)
+	}
+
+	if err := stageFileToFrontend(client, bytes.NewReader(env.description), path.Join(g5kStagingDirectory, env.descriptionFilename), env.descriptionChecksum); err != nil {
+		return "", fmt.Errorf("Failed to stage the environment description: %s", err)
+	}
+
+	return d.getStagedFileURL(env.descriptionFilename), nil
+}
+
+// splitEnvironmentName returns the name and the owner of an environment given with the 'name@user' syntax, the owner is empty if not given
+func splitEnvironmentName(image string) (string, string) {
+	if i := strings.LastIndex(image, "@"); i != -1 {
+		return image[:i], image[i+1:]
+	}
+	return image, ""
+}
+
+// newRecordedEnvironment returns the environment deployed on the node as registered in the Kadeploy3 database
+// The owner is resolved as for the deployment: the reference environments first, then the environments of the user
+func (d *Driver) newRecordedEnvironment() (*api.OperationEnvironment, error) {
+	// the custom environments staged from an archive or given by the URL of their description are not registered
+	if d.G5kImageArchive != "" || strings.Contains(d.G5kImage, "://") || strings.HasPrefix(d.G5kImage, "/") {
+		return nil, fmt.Errorf("The environment deployed on the node is not registered in Kadeploy, it cannot be booted without deploying it again (use the 'redeploy' restart mode)")
+	}
+
+	name, owner := splitEnvironmentName(d.G5kImage)
+	owners := []string{owner}
+	if owner == "" {
+		owners = []string{g5kReferenceEnvironmentOwner, d.G5kUsername}
+	}
+
+	for _, owner := range owners {
+		environments, err := d.g5kAPI.GetEnvironments(owner)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to retrieve the environments of the '%s' user: %s", owner, err)
+		}
+
+		for _, env := range environments {
+			if env.Name == name || env.Alias == name {
+				return &api.OperationEnvironment{Kind: "database", Name: env.Name, User: owner, Version: env.Version}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("The environment '%s' deployed on the node is not registered in Kadeploy", d.G5kImage)
+}
@@ -0,0 +1,88 @@
+package driver
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// Types of the events of the machine event log
+const (
+	eventJobSubmitted         string = "job_submitted"
+	eventJobStateChanged      string = "job_state_changed"
+	eventJobKilled            string = "job_killed"
+	eventOperationSubmitted   string = "operation_submitted"
+	eventOperationStepChanged string = "operation_step_changed"
+	eventOperationFinished    string = "operation_finished"
+	eventOperationFailed      string = "operation_failed"
+	eventPowerStatusObserved  string = "power_status_observed"
+	eventSSHCheck             string = "ssh_check"
+	eventError                string = "error"
+)
+
+// driverEvent represents an entry of the machine event log
+type driverEvent struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Type       string    `json:"type"`
+	Machine    string    `json:"machine"`
+	Site       string    `json:"site"`
+	JobID      int       `json:"job_id,omitempty"`
+	Node       string    `json:"node,omitempty"`
+	Operation  string    `json:"operation,omitempty"`
+	WorkflowID string    `json:"workflow_id,omitempty"`
+	State      string    `json:"state,omitempty"`
+	Duration   float64   `json:"duration_seconds,omitempty"`
+	Message    string    `json:"message,omitempty"`
+}
+
+// getEventLogPath returns the path of the event log in the machine directory
+func (d *Driver) getEventLogPath() string {
+	return d.ResolveStorePath("g5k-events.jsonl")
+}
+
+// logEvent append the event to the event log of the machine, in JSON lines format
+// The machine directory is only created by docker-machine after the pre-create checks, the events are kept in memory until then
+func (d *Driver) logEvent(event driverEvent) {
+	event.Timestamp = time.Now()
+	event.Machine = d.GetMachineName()
+	event.Site = d.G5kSite
+	if event.JobID == 0 {
+		event.JobID = d.G5kJobID
+	}
+	if event.Node == "" {
+		event.Node = d.G5kNodeHostname
+	}
+
+	d.pendingEvents = append(d.pendingEvents, event)
+
+	// wait for the machine directory to be created
+	if _, err := os.Stat(d.ResolveStorePath(".")); err != nil {
+		return
+	}
+
+	eventLog, err := os.OpenFile(d.getEventLogPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		log.Debugf("Failed to open the event log: %s", err)
+		return
+	}
+	defer eventLog.Close()
+
+	encoder := json.NewEncoder(eventLog)
+	for _, pendingEvent := range d.pendingEvents {
+		if err := encoder.Encode(pendingEvent); err != nil {
+			log.Debugf("Failed to write to the event log: %s", err)
+			return
+		}
+	}
+
+	d.pendingEvents = nil
+}
+
+// logErrorEvent append an error event to the event log of the machine if the action failed
+func (d *Driver) logErrorEvent(action string, err *error) {
+	if *err != nil {
+		d.logEvent(driverEvent{Type: eventError, Operation: action, Message: (*err).Error()})
+	}
+}
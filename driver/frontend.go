@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// getFrontendHostname returns the hostname of the frontend of the site
+func (d *Driver) getFrontendHostname() string {
+	return fmt.Sprintf("frontend.%s.grid5000.fr", d.G5kSite)
+}
+
+// getFrontendAuthMethods returns the SSH authentication methods for the user Grid'5000 account, and the function releasing them
+func (d *Driver) getFrontendAuthMethods() ([]ssh.AuthMethod, func(), error) {
+	// use the private key given by the user
+	if d.G5kFrontendSSHKeyPath != "" {
+		privateKey, err := ioutil.ReadFile(d.G5kFrontendSSHKeyPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to load the frontend SSH private key: %s", err)
+		}
+
+		signer, err := ssh.ParsePrivateKey(privateKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to parse the frontend SSH private key: %s", err)
+		}
+
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, func() {}, nil
+	}
+
+	// otherwise use the keys of the user SSH agent
+	agentSocket := os.Getenv("SSH_AUTH_SOCK")
+	if agentSocket == "" {
+		return nil, nil, fmt.Errorf("No SSH key is available to connect to the frontend, please start an SSH agent or provide a private key")
+	}
+
+	agentConn, err := net.Dial("unix", agentSocket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to connect to the SSH agent: %s", err)
+	}
+
+	return []ssh.AuthMethod{ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers)}, func() { agentConn.Close() }, nil
+}
+
+// dialFrontend opens an SSH connection to the frontend of the site using the user Grid'5000 account
+func (d *Driver) dialFrontend() (*ssh.Client, error) {
+	authMethods, releaseAuthMethods, err := d.getFrontendAuthMethods()
+	if err != nil {
+		return nil, err
+	}
+	// the authentication methods are only used during the handshake
+	defer releaseAuthMethods()
+
+	client, err := ssh.Dial("tcp", net.JoinHostPort(d.getFrontendHostname(), "22"), &ssh.ClientConfig{
+		User: d.G5kUsername,
+		Auth: authMethods,
+		// the frontend is only reachable through the Grid'5000 VPN
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to the frontend of the '%s' site: %s", d.G5kSite, err)
+	}
+
+	return client, nil
+}
+
+// runFrontendCommand run the command on the frontend and returns its output, stdin is optional
+func runFrontendCommand(client *ssh.Client, command string, stdin io.Reader) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("Failed to open a session on the frontend: %s", err)
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	if err := session.Run(command); err != nil {
+		return stdout.String(), fmt.Errorf("The command '%s' failed on the frontend: %s (%s)", command, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return stdout.String(), nil
+}
@@ -3,27 +3,184 @@ package driver
 import (
 	"encoding/base64"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Spirals-Team/docker-machine-driver-g5k/api"
 	"github.com/docker/machine/libmachine/log"
 )
 
+// g5kReferenceEnvironmentOwner is the Kadeploy3 user owning the Grid'5000 reference environments
+const g5kReferenceEnvironmentOwner string = "deploy"
+
+// g5kReferenceEnvironmentCacheTTL is the duration during which the cached reference environment name is used without querying the API
+const g5kReferenceEnvironmentCacheTTL time.Duration = 24 * time.Hour
+
+// g5kReferenceEnvironmentRegexp matches the name of the standard Debian environments, the reference environment is the most recent one
+var g5kReferenceEnvironmentRegexp = regexp.MustCompile(`^debian(\d+)(-x64)?-std$`)
+
+// NewAPIClient returns a new Grid'5000 API client, the API debug, record and replay modes can be enabled at runtime using the environment
+// An error is returned if the replay fixtures cannot be loaded, so the requests are never sent to the API by mistake
+func NewAPIClient(username string, password string, site string, debug bool, traceFile string) (*api.Client, error) {
+	client := api.NewClient(username, password, site)
+
+	// the API exchanges can be recorded as fixtures, and replayed to run the driver without the API
+	if replayFile := os.Getenv("G5K_API_REPLAY_FILE"); replayFile != "" {
+		if err := client.EnableReplay(replayFile, username); err != nil {
+			return nil, err
+		}
+	}
+	if recordFile := os.Getenv("G5K_API_RECORD_FILE"); recordFile != "" {
+		client.EnableRecording(recordFile, username)
+	}
+
+	if envTraceFile := os.Getenv("G5K_API_TRACE_FILE"); envTraceFile != "" {
+		traceFile = envTraceFile
+	}
+
+	envDebug, _ := strconv.ParseBool(os.Getenv("G5K_API_DEBUG"))
+	if debug || envDebug || traceFile != "" {
+		client.EnableTracing(log.Infof, traceFile)
+	}
+
+	return client, nil
+}
+
+// newAPIClient returns a new Grid'5000 API client configured with the driver parameters
+func (d *Driver) newAPIClient() (*api.Client, error) {
+	return NewAPIClient(d.G5kUsername, d.G5kPassword, d.G5kSite, d.G5kAPIDebug, d.G5kAPITraceFile)
+}
+
+// g5kJobCacheTTL is the duration during which the jobs are cached, the successive calls of a 'docker-machine ls' share them
+const g5kJobCacheTTL time.Duration = 5 * time.Second
+
+// g5kSiteJobsLockTimeout is the maximum duration a plugin process waits for the jobs of the site listed by another process
+const g5kSiteJobsLockTimeout time.Duration = 10 * time.Second
+
+// g5kVpnCheckCacheTTL is the duration during which a successful VPN check of the site is cached
+const g5kVpnCheckCacheTTL time.Duration = 30 * time.Second
+
+// g5kSiteJobsStates are the states of the jobs listed by the batch lookup, the jobs in other states are fetched one by one
+var g5kSiteJobsStates = []string{"waiting", "launching", "hold", "running"}
+
+// getCachedJob returns the job of the machine, the API is only queried when the cached job is outdated
+// The job is first looked up in the jobs of the user on the site, which are listed once for all the machines of the site
+func (d *Driver) getCachedJob() (*api.Job, error) {
+	job, cacheAge, err := d.loadCachedJob()
+	if err == nil && cacheAge < g5kJobCacheTTL {
+		return job, nil
+	}
+
+	if jobs, err := d.getCachedSiteJobs(); err != nil {
+		log.Debugf("Failed to list the jobs of the site: %s", err.Error())
+	} else {
+		for i := range jobs {
+			// the nodes of a running job are needed to reach the node
+			if jobs[i].UID == d.G5kJobID && (jobs[i].State != "running" || len(jobs[i].Nodes) > 0) {
+				return &jobs[i], nil
+			}
+		}
+	}
+
+	job, err = d.g5kAPI.GetJob(d.G5kJobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.storeCachedJob(job); err != nil {
+		log.Debugf("%s", err.Error())
+	}
+
+	return job, nil
+}
+
+// getCachedSiteJobs returns the jobs of the user on the site, only one plugin process lists them when the cache is outdated
+func (d *Driver) getCachedSiteJobs() ([]api.Job, error) {
+	deadline := time.Now().Add(g5kSiteJobsLockTimeout)
+	for {
+		jobs, cacheAge, err := d.loadCachedSiteJobs()
+		if err == nil && cacheAge < g5kJobCacheTTL {
+			return jobs, nil
+		}
+
+		if unlock, err := d.lockSiteJobsCache(); err == nil {
+			defer unlock()
+
+			jobs, err := d.g5kAPI.GetJobs(d.G5kUsername, g5kSiteJobsStates)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := d.storeCachedSiteJobs(jobs); err != nil {
+				log.Debugf("%s", err.Error())
+			}
+
+			return jobs, nil
+		}
+
+		// another plugin process is listing the jobs
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Timeout while waiting for the jobs of the site to be listed by another process")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// initAPIClient create the Grid'5000 API client of the driver if needed, the client and its connections are reused by the next calls
+func (d *Driver) initAPIClient() error {
+	if d.g5kAPI != nil {
+		return nil
+	}
+
+	client, err := d.newAPIClient()
+	if err != nil {
+		return err
+	}
+
+	d.g5kAPI = client
+	return nil
+}
+
 func (d *Driver) checkVpnConfiguration() error {
 	// Check VPN connection by trying to connect to the ssh server of the frontend of the current site.
 	// This allows to test if the user use the VPN and the Grid'5000 DNS servers.
-	if err := CheckSSHConnection(fmt.Sprintf("frontend.%s.grid5000.fr", d.G5kSite)); err != nil {
+	if err := CheckSSHConnection(d.getFrontendHostname()); err != nil {
 		return fmt.Errorf("Connection to frontend of '%s' site failed. Please check if the site is not undergoing maintenance and your VPN client is connected and properly configured (see driver documentation for more information)", d.G5kSite)
 	}
 
 	return nil
 }
 
+// checkCachedVpnConfiguration check the VPN connection, a successful check is cached for the site and shared by the plugin processes
+func (d *Driver) checkCachedVpnConfiguration() error {
+	if cacheAge, err := d.loadCachedVpnCheck(); err == nil && cacheAge < g5kVpnCheckCacheTTL {
+		return nil
+	}
+
+	if err := d.checkVpnConfiguration(); err != nil {
+		return err
+	}
+
+	if err := d.storeCachedVpnCheck(); err != nil {
+		log.Debugf("%s", err.Error())
+	}
+
+	return nil
+}
+
+// g5kJobProgressInterval is the interval between the progress reports of a waiting job
+const g5kJobProgressInterval time.Duration = time.Minute
+
 // waitUntilJobIsReady wait until the job reach the 'running' state (no timeout)
 func (d *Driver) waitUntilJobIsReady() error {
 	log.Info("Waiting for job to run...")
 
+	lastState := ""
+	lastProgress := time.Time{}
 	for {
 		// get job
 		job, err := d.g5kAPI.GetJob(d.G5kJobID)
@@ -31,6 +188,12 @@ func (d *Driver) waitUntilJobIsReady() error {
 			return err
 		}
 
+		// record the state changes, used to compute the waiting time of the job
+		if job.State != lastState {
+			d.logEvent(driverEvent{Type: eventJobStateChanged, State: job.State})
+			lastState = job.State
+		}
+
 		// check if the job is running
 		if job.State == "running" {
 			break
@@ -43,7 +206,14 @@ func (d *Driver) waitUntilJobIsReady() error {
 
 		// warn if job is in 'hold' state
 		if job.State == "hold" {
-			log.Infof("Job '%s' is in hold state, dont forget to resume it", d.G5kJobID)
+			log.Infof("Job '%d' is in hold state, dont forget to resume it", d.G5kJobID)
+		}
+
+		// report the progress with the scheduled start of the job (a reservation can wait for hours)
+		if job.State == "waiting" && job.ScheduledAt > 0 && time.Since(lastProgress) >= g5kJobProgressInterval {
+			scheduledAt := time.Unix(int64(job.ScheduledAt), 0)
+			log.Infof("Job '%d' is scheduled to start at %s (in %s)", d.G5kJobID, FormatReservationDate(scheduledAt), time.Until(scheduledAt).Truncate(time.Second))
+			lastProgress = time.Now()
 		}
 
 		// wait 3 seconds before making another API call
@@ -54,8 +224,272 @@ func (d *Driver) waitUntilJobIsReady() error {
 	return nil
 }
 
-// makeJobSubmission submit a job submission to Grid'5000
-func (d *Driver) makeJobSubmission() error {
+// Causes of the termination of a job, deduced from the OAR job events
+const (
+	jobTerminationUnknown    string = "unknown"
+	jobTerminationWalltime   string = "walltime"
+	jobTerminationUserKill   string = "user-kill"
+	jobTerminationNodeReboot string = "node-reboot"
+	jobTerminationPreempted  string = "preempted"
+)
+
+// jobTerminationEvents stores the cause of the termination for each OAR job event, by order of precedence
+// OAR also adds a FRAG_JOB_REQUEST event when it kills a job at the end of its walltime or to free the resources of a besteffort job,
+// so this event only means the job was killed by its user when there is no other cause
+var jobTerminationEvents = []struct {
+	events []string
+	cause  string
+}{
+	// the besteffort job has been killed by OAR to free the resources for a regular job
+	{[]string{"BESTEFFORT_KILL"}, jobTerminationPreempted},
+	{[]string{"WALLTIME"}, jobTerminationWalltime},
+	{[]string{"FRAG_JOB_REQUEST"}, jobTerminationUserKill},
+	// the node became unreachable, in the reference environment this is caused by a reboot
+	{[]string{"PING_CHECKER_NODE_SUSPECTED", "PING_CHECKER_NODE_SUSPECTED_END_JOB"}, jobTerminationNodeReboot},
+}
+
+// getJobTerminationCause returns the cause of the termination of the job from its events, whatever their order
+func getJobTerminationCause(job *api.Job) string {
+	events := make(map[string]bool)
+	for _, event := range job.Events {
+		events[event.Type] = true
+	}
+
+	for _, termination := range jobTerminationEvents {
+		for _, event := range termination.events {
+			if events[event] {
+				return termination.cause
+			}
+		}
+	}
+	return jobTerminationUnknown
+}
+
+// g5kNodeBootTimeout is the maximum duration to wait for the SSH server of the node after a power-on
+const g5kNodeBootTimeout time.Duration = 10 * time.Minute
+
+// checkJobIsRunning returns an error describing the job state if it is not running, as the node is only allocated during this state
+func checkJobIsRunning(job *api.Job) error {
+	switch job.State {
+	case "running":
+		return nil
+	case "waiting", "launching", "hold":
+		return fmt.Errorf("The job (id: %d) is not running yet (state: '%s')", job.UID, job.State)
+	case "error", "terminated":
+		return fmt.Errorf("The job (id: %d) is no longer running (state: '%s', cause: '%s'), the node is not allocated anymore", job.UID, job.State, getJobTerminationCause(job))
+	default:
+		return fmt.Errorf("The job (id: %d) is in an unexpected state: %s", job.UID, job.State)
+	}
+}
+
+// waitUntilNodeIsReachable wait until the SSH server of the node is reachable
+func (d *Driver) waitUntilNodeIsReachable(timeout time.Duration) error {
+	node, err := d.GetIP()
+	if err != nil {
+		return fmt.Errorf("Failed to get the node hostname: %s", err.Error())
+	}
+
+	log.Infof("Waiting for the SSH server of the '%s' node to be reachable...", node)
+
+	start := time.Now()
+	for CheckSSHConnection(node) != nil {
+		if time.Since(start) > timeout {
+			d.logEvent(driverEvent{Type: eventSSHCheck, Node: node, State: "unreachable", Duration: time.Since(start).Seconds()})
+			return fmt.Errorf("The SSH server of the '%s' node is still unreachable after %s", node, timeout)
+		}
+
+		// wait before making another attempt
+		time.Sleep(5 * time.Second)
+	}
+
+	d.logEvent(driverEvent{Type: eventSSHCheck, Node: node, State: "reachable", Duration: time.Since(start).Seconds()})
+	return nil
+}
+
+// submitReplacementJob submit a new job with the same parameters as the terminated one, its node then needs to be set up by setupReplacementNode
+func (d *Driver) submitReplacementJob() error {
+	terminatedJobID := d.G5kJobID
+
+	if err := d.prepareDriverStoreDirectory(); err != nil {
+		return err
+	}
+
+	// the driver key could have been regenerated since the creation of the machine
+	if err := d.loadDriverSSHPublicKey(); err != nil {
+		return err
+	}
+
+	// the cache of the terminated job would never be removed once the machine uses the new job
+	d.removeCachedJob()
+
+	// the SSH keys are injected by the job command when reusing the reference environment
+	if err := d.makeJobSubmission(); err != nil {
+		return err
+	}
+
+	// the node will be resolved from the new job
+	d.G5kNodeHostname = ""
+	d.IPAddress = ""
+	d.G5kNodeSetupPending = true
+
+	// save the new job now, so it will not be lost if the following steps fail
+	if err := d.saveMachineConfig(); err != nil {
+		return err
+	}
+
+	log.Infof("The job (id: %d) replaces the terminated job (id: %d)", d.G5kJobID, terminatedJobID)
+	return nil
+}
+
+// setupReplacementNode wait for the replacement job to run, then deploy its node and update the machine to use it
+func (d *Driver) setupReplacementNode() error {
+	if err := d.waitUntilJobIsReady(); err != nil {
+		return err
+	}
+
+	if err := d.deployImageToNode(); err != nil {
+		return err
+	}
+
+	if err := d.installDriverSSHKey(); err != nil {
+		return err
+	}
+
+	if err := d.waitUntilNodeIsReachable(g5kNodeBootTimeout); err != nil {
+		return err
+	}
+
+	if err := d.applyUserData(); err != nil {
+		return err
+	}
+
+	node, err := d.GetIP()
+	if err != nil {
+		return err
+	}
+
+	// docker-machine does not save the machine when the start action fails, which will happen as Docker is not provisioned on the new node
+	d.G5kNodeSetupPending = false
+	if err := d.saveMachineConfig(); err != nil {
+		return err
+	}
+
+	log.Infof("The machine now uses the '%s' node of the job (id: %d)", node, d.G5kJobID)
+	log.Warnf("Docker is not installed on the new node, please run 'docker-machine provision %s'", d.GetMachineName())
+	return nil
+}
+
+// checkJobReplacement returns an error if the terminated job of the machine must not be replaced by a new job
+func (d *Driver) checkJobReplacement(job *api.Job) error {
+	// the machines created by the previous versions of the driver cannot tell if their job was given by the user, so it is not replaced
+	if !d.G5kJobOwnershipRecorded {
+		return fmt.Errorf("The job (id: %d) is no longer running (cause: '%s'), the machine was created by a previous version of the driver which does not replace its job, the machine needs to be recreated", d.G5kJobID, getJobTerminationCause(job))
+	}
+
+	// the jobs given by the user (resource reservations) are never replaced by a job owned by the driver
+	if !d.G5kJobSubmittedByDriver {
+		return fmt.Errorf("The job (id: %d) given at the creation of the machine is no longer running (cause: '%s'), the machine needs to be recreated with another job", d.G5kJobID, getJobTerminationCause(job))
+	}
+
+	// in the reference environment, replacing the job after a reboot of the node is opt-in
+	if d.G5kReuseRefEnvironment && getJobTerminationCause(job) == jobTerminationNodeReboot && !d.G5kRecreateOnReboot {
+		return fmt.Errorf("The job (id: %d) has been terminated because the node rebooted, the machine needs to be recreated", d.G5kJobID)
+	}
+
+	return nil
+}
+
+// g5kBesteffortResubmitAttempts is the maximum number of jobs submitted by 'start' when the new besteffort jobs are preempted
+const g5kBesteffortResubmitAttempts int = 3
+
+// recreateJob submit a new job with the same parameters as the terminated one, deploy its node and update the machine to use it
+// A new besteffort job preempted before its node is set up is replaced again when the resubmission is enabled
+func (d *Driver) recreateJob() error {
+	for attempt := 1; ; attempt++ {
+		if err := d.submitReplacementJob(); err != nil {
+			return err
+		}
+
+		err := d.setupReplacementNode()
+		if err == nil || !d.G5kBesteffortResubmit || attempt >= g5kBesteffortResubmitAttempts || !d.isJobPreempted() {
+			return err
+		}
+
+		log.Infof("The besteffort job (id: %d) has been preempted before its node was set up, submitting a new job... (attempt %d/%d)", d.G5kJobID, attempt+1, g5kBesteffortResubmitAttempts)
+	}
+}
+
+// isJobPreempted returns true if the job of the machine has been killed by OAR to free its resources for a regular job
+func (d *Driver) isJobPreempted() bool {
+	job, err := d.g5kAPI.GetJob(d.G5kJobID)
+	if err != nil {
+		return false
+	}
+
+	return (job.State == "error" || job.State == "terminated") && getJobTerminationCause(job) == jobTerminationPreempted
+}
+
+// fetchReferenceEnvironment returns the name of the reference environment of the site from the Kadeploy3 API
+func (d *Driver) fetchReferenceEnvironment() (string, error) {
+	environments, err := d.g5kAPI.GetEnvironments(g5kReferenceEnvironmentOwner)
+	if err != nil {
+		return "", err
+	}
+
+	// select the standard environment of the most recent Debian release
+	refEnvName := ""
+	refEnvRelease := 0
+	for _, env := range environments {
+		matches := g5kReferenceEnvironmentRegexp.FindStringSubmatch(env.Name)
+		if matches == nil {
+			continue
+		}
+
+		release, err := strconv.Atoi(matches[1])
+		if err != nil {
+			continue
+		}
+
+		if release > refEnvRelease {
+			refEnvName = env.Name
+			refEnvRelease = release
+		}
+	}
+
+	if refEnvName == "" {
+		return "", fmt.Errorf("No reference environment is available on the '%s' site", d.G5kSite)
+	}
+
+	return refEnvName, nil
+}
+
+// resolveReferenceEnvironment returns the name of the reference environment of the site, the driver storage directory is used as cache
+func (d *Driver) resolveReferenceEnvironment() (string, error) {
+	cachedRefEnv, cacheAge, cacheErr := d.loadCachedReferenceEnvironment()
+	if cacheErr == nil && cacheAge < g5kReferenceEnvironmentCacheTTL {
+		return cachedRefEnv, nil
+	}
+
+	refEnv, err := d.fetchReferenceEnvironment()
+	if err != nil {
+		// fallback to the outdated cached value when the API is not reachable
+		if cacheErr == nil {
+			log.Warnf("Failed to retrieve the reference environment of the '%s' site, using the cached value '%s': %s", d.G5kSite, cachedRefEnv, err.Error())
+			return cachedRefEnv, nil
+		}
+
+		return "", fmt.Errorf("Failed to retrieve the reference environment of the '%s' site: %s", d.G5kSite, err.Error())
+	}
+
+	if err := d.storeCachedReferenceEnvironment(refEnv); err != nil {
+		log.Warnf("%s", err.Error())
+	}
+
+	return refEnv, nil
+}
+
+// getJobCommandAndTypes returns the command and the types of a new job depending on the usage of the reference environment
+func (d *Driver) getJobCommandAndTypes() (string, []string) {
 	// by default, the node will be redeployed with another image, no specific actions are needed
 	jobCommand := "sleep 365d"
 	jobTypes := ArrayRemoveDuplicate(append(d.G5kJobTypes, "deploy"))
@@ -70,94 +504,218 @@ func (d *Driver) makeJobSubmission() error {
 		jobCommand = fmt.Sprint(`sudo-g5k && printf ` + sshAuthorizedKeysBase64 + ` |base64 -d |sudo tee -a /root/.ssh/authorized_keys >/dev/null && sleep 365d`)
 	}
 
-	// submit new Job request
-	jobID, err := d.g5kAPI.SubmitJob(api.JobRequest{
-		Resources:  fmt.Sprintf("nodes=1,walltime=%s", d.G5kWalltime),
+	return jobCommand, jobTypes
+}
+
+// newResourceRequest returns the resources requested by the job of the machine, checked before the submission
+func (d *Driver) newResourceRequest() (*api.ResourceRequest, error) {
+	levels, err := api.ParseResourceLevels(d.G5kTopology)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid topology: %s", err)
+	}
+
+	// the whole nodes are reserved, the deployment and the reference environment reuse (sudo-g5k) are not possible on a part of a node
+	for _, level := range levels {
+		if level.IsPartOfNode() {
+			return nil, fmt.Errorf("Invalid topology: the '%s' level selects a part of a node, the driver always reserves whole nodes to deploy them or to reuse the reference environment", level)
+		}
+	}
+
+	// the machines created by older versions of the driver have no number of nodes
+	nodes := d.G5kNodes
+	if nodes == 0 {
+		nodes = 1
+	}
+	levels = append(levels, api.ResourceLevel{Name: "nodes", Count: nodes})
+
+	// the GPUs only select the nodes, as the whole nodes are reserved
+	filter := ""
+	if d.G5kGPUs > 0 {
+		filter = fmt.Sprintf("gpu_count >= %d", d.G5kGPUs)
+	}
+
+	resources := api.NewResourceRequest(d.G5kWalltime).AddGroup(filter, levels...)
+
+	// the subnets are not part of the node hierarchy, each one is requested in its own group
+	subnets, err := api.ParseResourceLevels(d.G5kSubnets)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid subnets: %s", err)
+	}
+	for _, subnet := range subnets {
+		if !subnet.IsStandalone() {
+			return nil, fmt.Errorf("Invalid subnets: '%s' is not a subnet resource (ex: 'slash_22=1')", subnet)
+		}
+		resources.AddGroup("", subnet)
+	}
+	if err := resources.Validate(); err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// newJobRequest returns the job request submitted to Grid'5000 for the machine (without reservation)
+func (d *Driver) newJobRequest() (api.JobRequest, error) {
+	resources, err := d.newResourceRequest()
+	if err != nil {
+		return api.JobRequest{}, err
+	}
+
+	jobCommand, jobTypes := d.getJobCommandAndTypes()
+
+	return api.JobRequest{
+		Name:       GetJobName(d.StorePath, d.GetMachineName()),
+		Project:    d.G5kProject,
+		Resources:  resources.String(),
 		Command:    jobCommand,
 		Properties: d.G5kResourceProperties,
 		Types:      jobTypes,
 		Queue:      d.G5kJobQueue,
-	})
+	}, nil
+}
+
+// makeJobSubmission submit a job submission to Grid'5000
+func (d *Driver) makeJobSubmission() error {
+	jobRequest, err := d.newJobRequest()
+	if err != nil {
+		return err
+	}
+
+	// submit new Job request
+	jobID, err := d.g5kAPI.SubmitJob(jobRequest)
 	if err != nil {
 		return fmt.Errorf("Error when submitting new job: %s", err.Error())
 	}
 
 	log.Infof("Job submission have been successfully submitted. (job id: %d)", jobID)
 	d.G5kJobID = jobID
+	d.G5kJobSubmittedByDriver = true
+	d.logEvent(driverEvent{Type: eventJobSubmitted, Message: fmt.Sprintf("queue: %s, types: %s", d.G5kJobQueue, strings.Join(jobRequest.Types, ","))})
 	return nil
 }
 
 // makeJobReservation submit a job reservation to Grid'5000
 func (d *Driver) makeJobReservation() error {
-	jobCommand := "sleep 365d"
-	jobTypes := ArrayRemoveDuplicate(append(d.G5kJobTypes, "deploy"))
+	// when reusing the reference environment, the SSH keys are injected by the job command at the start of the reservation
+	jobRequest, err := d.newJobRequest()
+	if err != nil {
+		return err
+	}
+	jobRequest.Reservation = d.G5kJobStartTime
 
 	// submit new Job request
-	jobID, err := d.g5kAPI.SubmitJob(api.JobRequest{
-		Resources:   fmt.Sprintf("nodes=1,walltime=%s", d.G5kWalltime),
-		Command:     jobCommand,
-		Properties:  d.G5kResourceProperties,
-		Reservation: d.G5kJobStartTime,
-		Types:       jobTypes,
-		Queue:       d.G5kJobQueue,
-	})
+	jobID, err := d.g5kAPI.SubmitJob(jobRequest)
 	if err != nil {
 		return fmt.Errorf("Error when submitting new job: %s", err.Error())
 	}
 
 	log.Infof("Job reservation have been successfully submitted. (job id: %d)", jobID)
 	d.G5kJobID = jobID
+	d.G5kJobSubmittedByDriver = true
+	d.logEvent(driverEvent{Type: eventJobSubmitted, Message: fmt.Sprintf("queue: %s, types: %s, reservation: %s", d.G5kJobQueue, strings.Join(jobRequest.Types, ","), d.G5kJobStartTime)})
 	return nil
 }
 
+// rollbackJob kill the job submitted by the driver after a failure of the machine creation, the jobs given by the user are never killed
+func (d *Driver) rollbackJob() {
+	if !d.G5kJobSubmittedByDriver || d.G5kJobID == 0 {
+		return
+	}
+
+	if d.G5kKeepOnFailure {
+		log.Warnf("The machine creation failed, the job (id: %d) is kept as requested, remove the machine or use the 'jobs cleanup' command to kill it", d.G5kJobID)
+		return
+	}
+
+	log.Infof("The machine creation failed, killing the job submitted by the driver... (job id: %d)", d.G5kJobID)
+	if err := d.g5kAPI.KillJob(d.G5kJobID); err != nil {
+		log.Warnf("Failed to kill the job (id: %d), remove the machine or use the 'jobs cleanup' command to kill it: %s", d.G5kJobID, err)
+		return
+	}
+
+	log.Infof("The job (id: %d) have been killed", d.G5kJobID)
+	d.removeCachedJob()
+	d.logEvent(driverEvent{Type: eventJobKilled, Message: "rollback of the machine creation"})
+
+	// the reservations made by the driver for the machine cannot be used anymore
+	if _, err := d.getReservationRegistry().Update(d.G5kSite, strconv.Itoa(d.G5kJobID), func(r *Reservation) {
+		r.Cancelled = true
+		r.Pending = false
+	}); err != nil {
+		log.Warnf("Failed to mark the reservation as cancelled in the registry: %s", err)
+	}
+}
+
+// g5kWorkflowPollInterval is the interval between the API calls following the workflow of an operation
+const g5kWorkflowPollInterval time.Duration = 7 * time.Second
+
+// g5kPowerStatusRequestTimeout is the duration after which a pending power status request followed by GetState is abandoned
+const g5kPowerStatusRequestTimeout time.Duration = 10 * time.Minute
+
+// g5kDefaultPowerStateCacheTTL is the default duration (in seconds) during which the power status of the node is cached
+const g5kDefaultPowerStateCacheTTL int = 300
+
+// g5kPowerStateUnknown is the cached power status of the node when its request failed, it is not requested again before the end of the TTL
+const g5kPowerStateUnknown string = "unknown"
+
 // waitUntilWorkflowIsDone will wait until the workflow for the given operation is done (successfully or not) for the node
+// There is no timeout, the operations must not be abandoned while kadeploy is processing the node
 func (d *Driver) waitUntilWorkflowIsDone(operation string, wid string, node string) error {
 	log.Infof("Waiting for workflow of '%s' operation to finish, it will take a few minutes...", operation)
 
-	for {
-		// get operation workflow
-		workflow, err := d.g5kAPI.GetOperationWorkflow(operation, wid)
-		if err != nil {
-			return err
-		}
-
-		// check if the workflow is done for the node
-		if ArrayContainsString(workflow.Nodes["ok"], node) {
-			break
-		}
+	start := time.Now()
+	lastStep := ""
+	err := d.g5kAPI.WaitForWorkflow(operation, wid, node, g5kWorkflowPollInterval, 0, func() {
+		log.Debugf("Workflow for '%s' operation is in processing state for the '%s' node", operation, node)
 
-		// check if the workflow failed for the node
-		if ArrayContainsString(workflow.Nodes["ko"], node) {
-			return fmt.Errorf("Workflow for '%s' operation failed for the '%s' node", operation, node)
+		// record the steps of the workflow, used to compute the duration of each step
+		if states, err := d.g5kAPI.GetOperationStates(operation, wid); err == nil {
+			if nodeState, ok := (*states)[node]; ok && nodeState.Macro+"/"+nodeState.Micro != lastStep {
+				lastStep = nodeState.Macro + "/" + nodeState.Micro
+				d.logEvent(driverEvent{Type: eventOperationStepChanged, Node: node, Operation: operation, WorkflowID: wid, State: lastStep})
+			}
 		}
-
-		// check if the workflow is processing the node
-		if ArrayContainsString(workflow.Nodes["processing"], node) {
-			log.Debugf("Workflow for '%s' operation is in processing state for the '%s' node", operation, node)
+	})
+	if err != nil {
+		if _, ok := err.(*api.WorkflowError); ok {
+			d.logEvent(driverEvent{Type: eventOperationFailed, Node: node, Operation: operation, WorkflowID: wid, Duration: time.Since(start).Seconds(), Message: err.Error()})
 		}
-
-		// wait before making another API call
-		time.Sleep(7 * time.Second)
+		return err
 	}
 
+	d.logEvent(driverEvent{Type: eventOperationFinished, Node: node, Operation: operation, WorkflowID: wid, Duration: time.Since(start).Seconds()})
 	log.Infof("Workflow for '%s' operation finished successfully for the '%s' node", operation, node)
 	return nil
 }
 
-// deployImageToNode start the deployment of an OS image to a node
-func (d *Driver) deployImageToNode() error {
-	// if the user want to reuse Grid'5000 reference environment
-	if d.G5kReuseRefEnvironment {
-		log.Infof("Skipping image deployment and reusing Grid'5000 standard environment")
-		return nil
+// newDeploymentRequest returns the deployment request submitted to kadeploy for the given node
+func (d *Driver) newDeploymentRequest(node string) api.DeploymentRequest {
+	return api.DeploymentRequest{
+		Nodes:       []string{node},
+		Environment: d.G5kImage,
+		Key:         GenerateSSHAuthorizedKeys(d.DriverSSHPublicKey, d.ExternalSSHPublicKeys),
 	}
+}
 
+// deployImageToNode start the deployment of an OS image to a node
+func (d *Driver) deployImageToNode() error {
 	// get job informations
 	job, err := d.g5kAPI.GetJob(d.G5kJobID)
 	if err != nil {
 		return fmt.Errorf("Error when getting job (id: '%d') informations: %s", d.G5kJobID, err.Error())
 	}
 
+	// if the user want to reuse Grid'5000 reference environment
+	if d.G5kReuseRefEnvironment {
+		// the reference environment is not available on the nodes of a job of type 'deploy'
+		if ArrayContainsString(job.Types, "deploy") {
+			return fmt.Errorf("The job (id: %d) must not have the type 'deploy' to reuse the Grid'5000 reference environment", d.G5kJobID)
+		}
+
+		log.Infof("Skipping image deployment and reusing Grid'5000 standard environment")
+		return nil
+	}
+
 	// check job type before deploying
 	if !ArrayContainsString(job.Types, "deploy") {
 		return fmt.Errorf("The job (id: %d) needs to have the type 'deploy'", d.G5kJobID)
@@ -174,20 +732,27 @@ func (d *Driver) deployImageToNode() error {
 		return fmt.Errorf("The node '%s' is not allocated to the job (id: %d)", node, d.G5kJobID)
 	}
 
+	// stage the custom environment on the frontend, the deployment will use the staged environment description
+	if d.G5kImageArchive != "" {
+		descriptionURL, err := d.stageCustomEnvironment()
+		if err != nil {
+			return fmt.Errorf("Error when staging the custom environment: %s", err.Error())
+		}
+
+		d.G5kImage = descriptionURL
+	}
+
 	log.Infof("Submitting a new deployment for node '%s'... (image: '%s')", node, d.G5kImage)
 
 	// submit deployment operation to kadeploy
-	op, err := d.g5kAPI.SubmitDeployment(api.DeploymentRequest{
-		Nodes:       []string{node},
-		Environment: d.G5kImage,
-		Key:         GenerateSSHAuthorizedKeys(d.DriverSSHPublicKey, d.ExternalSSHPublicKeys),
-	})
+	op, err := d.g5kAPI.SubmitDeployment(d.newDeploymentRequest(node))
 
 	if err != nil {
 		return fmt.Errorf("Error when submitting new deployment: %s", err.Error())
 	}
 
 	log.Infof("Deployment operation for '%s' node have been submitted successfully (workflow id: '%s')", node, op.UID)
+	d.logEvent(driverEvent{Type: eventOperationSubmitted, Node: node, Operation: "deployment", WorkflowID: op.UID, Message: fmt.Sprintf("image: %s", d.G5kImage)})
 
 	// waiting deployment to finish (REQUIRED or you will interfere with kadeploy)
 	if err = d.waitUntilWorkflowIsDone("deployment", op.UID, node); err != nil {
@@ -209,38 +774,152 @@ func (d *Driver) getNodePowerState() (string, error) {
 		return "", fmt.Errorf("Failed to request power status: %s", err.Error())
 	}
 
+	d.logEvent(driverEvent{Type: eventOperationSubmitted, Node: node, Operation: "power", WorkflowID: op.WID, Message: "status request"})
+
 	if err := d.waitUntilWorkflowIsDone("power", op.WID, node); err != nil {
 		return "", err
 	}
 
-	// get nodes states for the workflow
-	states, err := d.g5kAPI.GetOperationStates("power", op.WID)
+	return d.getPowerStatusResult(node, op.WID)
+}
+
+// getPowerStatusResult returns the power status of the node from the states of the done power status workflow
+func (d *Driver) getPowerStatusResult(node string, wid string) (string, error) {
+	states, err := d.g5kAPI.GetOperationStates("power", wid)
+	if err != nil {
+		return "", err
+	}
+
+	// extract the BMC power status from the state of the current node
+	powerStatus, err := states.PowerStatus(node)
 	if err != nil {
 		return "", err
 	}
 
-	// get the state of the current node
-	state, ok := (*states)[node]
-	if !ok {
-		return "", fmt.Errorf("Failed to retrieve the workflow state of the power status operation")
+	d.logEvent(driverEvent{Type: eventPowerStatusObserved, Node: node, WorkflowID: wid, State: powerStatus})
+
+	return powerStatus, nil
+}
+
+// getPowerStateCacheTTL returns the duration during which the power status of the node is cached
+// The machines created before the power status cache have a zero TTL, they use the default TTL
+func (d *Driver) getPowerStateCacheTTL() time.Duration {
+	ttl := d.G5kPowerStateCacheTTL
+	if ttl == 0 {
+		ttl = g5kDefaultPowerStateCacheTTL
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// getCachedNodePowerState returns the cached power status of the node without waiting for the BMC, as it is used when listing the machines
+// When the cached status is outdated, its refresh is followed (or started) and the outdated status is returned until the refresh is done
+func (d *Driver) getCachedNodePowerState() (string, error) {
+	powerState, cacheAge, err := d.loadCachedPowerState()
+	if err == nil && cacheAge < d.getPowerStateCacheTTL() {
+		return powerState, nil
+	}
+
+	if refreshedState, ok := d.refreshCachedNodePowerState(); ok {
+		return refreshedState, nil
 	}
 
-	// extract the BMC power status from the state out attribute
-	re := regexp.MustCompile(`-bmc: (on|off)$`)
-	matches := re.FindStringSubmatch(state.Out)
-	if matches == nil {
-		return "", fmt.Errorf("The BMC status in the workflow state is invalid: %s", state.Out)
+	if err != nil {
+		return "", fmt.Errorf("The power status of the node is not known yet")
 	}
 
-	return matches[1], nil
+	return powerState, nil
 }
 
-// changeNodePowerStatus change the power status (on/off) of the node with the given level (soft/hard)
-func (d *Driver) changeNodePowerStatus(status string, level string) error {
+// refreshCachedNodePowerState follow the pending power status request of the node without waiting for it, or submit a new one
+// It returns the new power status when the request is done, a failed request is cached as unknown so it is not submitted again before the end of the TTL
+func (d *Driver) refreshCachedNodePowerState() (string, bool) {
+	node, err := d.GetIP()
+	if err != nil {
+		return "", false
+	}
+
+	powerState, err := d.pollPowerStatusRequest(node)
+	if err != nil {
+		log.Debugf("Failed to get the power status of the node: %s", err.Error())
+		powerState = g5kPowerStateUnknown
+	} else if powerState == "" {
+		return "", false
+	}
+
+	if err := d.storeCachedPowerState(powerState); err != nil {
+		log.Debugf("%s", err.Error())
+	}
+
+	return powerState, true
+}
+
+// pollPowerStatusRequest returns the power status of the node if its pending request is done, or an empty status if it is still processing
+// Each request spawns a kadeploy workflow, a new request is only submitted when there is no pending one
+func (d *Driver) pollPowerStatusRequest(node string) (string, error) {
+	wid, requestAge, err := d.loadCachedPowerStatusRequest()
+	if err != nil {
+		op, err := d.g5kAPI.RequestPowerStatus(node)
+		if err != nil {
+			return "", fmt.Errorf("Failed to request power status: %s", err.Error())
+		}
+
+		d.logEvent(driverEvent{Type: eventOperationSubmitted, Node: node, Operation: "power", WorkflowID: op.WID, Message: "status request"})
+		if err := d.storeCachedPowerStatusRequest(op.WID); err != nil {
+			return "", err
+		}
+
+		return "", nil
+	}
+
+	workflow, err := d.g5kAPI.GetOperationWorkflow("power", wid)
+	if err != nil {
+		return "", err
+	}
+
+	if ArrayContainsString(workflow.Nodes["ok"], node) {
+		return d.getPowerStatusResult(node, wid)
+	}
+	if ArrayContainsString(workflow.Nodes["ko"], node) {
+		return "", &api.WorkflowError{Operation: "power", WID: wid, Node: node}
+	}
+	if requestAge > g5kPowerStatusRequestTimeout {
+		return "", &api.WorkflowError{Operation: "power", WID: wid, Node: node, TimedOut: true}
+	}
+
+	return "", nil
+}
+
+// setNodePowerStatus change the power status (on/off) of the node with the given level (soft/hard) only if needed
+// After a power-on, it waits until the SSH server of the node is reachable
+func (d *Driver) setNodePowerStatus(status string, level string) error {
 	if d.G5kReuseRefEnvironment {
 		return fmt.Errorf("You can't power-%s (%s) the node when reusing the Grid'5000 environment", status, level)
 	}
 
+	// the cached power status can be outdated, the BMC is always queried before changing it
+	currentStatus, err := d.getNodePowerState()
+	if err != nil {
+		return err
+	}
+
+	if currentStatus == status {
+		log.Infof("The node is already powered %s, skipping the power-%s (%s) operation", status, status, level)
+		if err := d.storeCachedPowerState(currentStatus); err != nil {
+			log.Debugf("%s", err.Error())
+		}
+	} else if err := d.changeNodePowerStatus(status, level); err != nil {
+		return err
+	}
+
+	if status == "on" {
+		return d.waitUntilNodeIsReachable(g5kNodeBootTimeout)
+	}
+
+	return nil
+}
+
+// changeNodePowerStatus change the power status (on/off) of the node with the given level (soft/hard)
+func (d *Driver) changeNodePowerStatus(status string, level string) error {
 	node, err := d.GetIP()
 	if err != nil {
 		return fmt.Errorf("Failed to get the node hostname: %s", err.Error())
@@ -257,11 +936,21 @@ func (d *Driver) changeNodePowerStatus(status string, level string) error {
 	}
 
 	log.Infof("Power-%s (%s) operation for '%s' node have been submitted successfully (workflow id: '%s')", status, level, node, op.WID)
-	return d.waitUntilWorkflowIsDone("power", op.WID, node)
+	d.logEvent(driverEvent{Type: eventOperationSubmitted, Node: node, Operation: "power", WorkflowID: op.WID, Message: fmt.Sprintf("power-%s (%s)", status, level)})
+	if err := d.waitUntilWorkflowIsDone("power", op.WID, node); err != nil {
+		return err
+	}
+
+	// the power status of the node is now known
+	if err := d.storeCachedPowerState(status); err != nil {
+		log.Debugf("%s", err.Error())
+	}
+
+	return nil
 }
 
-// rebootNode reboot the node with the given level (soft/hard)
-func (d *Driver) rebootNode(level string) error {
+// rebootNode reboot the node with the given kind (simple/set_pxe/recorded_env/deploy_env) and level (soft/hard)
+func (d *Driver) rebootNode(kind string, level string) error {
 	if d.G5kReuseRefEnvironment {
 		return fmt.Errorf("You can't reboot (%s) the node when reusing the Grid'5000 environment", level)
 	}
@@ -271,16 +960,97 @@ func (d *Driver) rebootNode(level string) error {
 		return fmt.Errorf("Failed to get the node hostname: %s", err.Error())
 	}
 
-	op, err := d.g5kAPI.SubmitRebootOperation(api.RebootOperation{
-		Kind:  "simple",
+	operation := api.RebootOperation{
+		Kind:  kind,
 		Nodes: []string{node},
 		Level: level,
-	})
+	}
+
+	switch kind {
+	case "recorded_env":
+		// boot the environment deployed on the node without deploying it again
+		env, err := d.newRecordedEnvironment()
+		if err != nil {
+			return err
+		}
+		operation.Environment = env
+	case "set_pxe":
+		profilePath := os.Getenv("G5K_RESTART_PXE_PROFILE")
+		if profilePath == "" {
+			return fmt.Errorf("The PXE profile needs to be given with the G5K_RESTART_PXE_PROFILE environment variable")
+		}
+
+		profile, err := ioutil.ReadFile(profilePath)
+		if err != nil {
+			return fmt.Errorf("Failed to read the PXE profile: %s", err.Error())
+		}
+
+		operation.PXE = &api.OperationPXE{Profile: string(profile)}
+	}
 
+	op, err := d.g5kAPI.SubmitRebootOperation(operation)
 	if err != nil {
 		return err
 	}
 
-	log.Infof("Reboot (%s) operation for '%s' node have been submitted successfully (workflow id: '%s')", level, node, op.WID)
-	return d.waitUntilWorkflowIsDone("reboot", op.WID, node)
+	log.Infof("Reboot (%s, %s) operation for '%s' node have been submitted successfully (workflow id: '%s')", kind, level, node, op.WID)
+	d.logEvent(driverEvent{Type: eventOperationSubmitted, Node: node, Operation: "reboot", WorkflowID: op.WID, Message: fmt.Sprintf("%s (%s)", kind, level)})
+	if err := d.waitUntilWorkflowIsDone("reboot", op.WID, node); err != nil {
+		return err
+	}
+
+	// the node is powered on after a reboot
+	if err := d.storeCachedPowerState("on"); err != nil {
+		log.Debugf("%s", err.Error())
+	}
+
+	return nil
+}
+
+// rebootNodeWithEscalation perform a soft reboot of the node, and a hard reboot if its workflow fails or if the node does not come back
+func (d *Driver) rebootNodeWithEscalation() error {
+	err := d.rebootNode("simple", "soft")
+	if err == nil {
+		err = d.waitUntilNodeIsReachable(g5kNodeBootTimeout)
+	} else if _, ok := err.(*api.WorkflowError); !ok {
+		// the reboot request has been rejected, a hard reboot would be rejected too
+		return err
+	}
+
+	if err != nil {
+		log.Warnf("The soft reboot of the node failed, trying a hard reboot: %s", err.Error())
+		if err := d.rebootNode("simple", "hard"); err != nil {
+			return err
+		}
+
+		return d.waitUntilNodeIsReachable(g5kNodeBootTimeout)
+	}
+
+	return nil
+}
+
+// redeployNode deploy the image again on the node of the current job, without removing the machine
+func (d *Driver) redeployNode() error {
+	if d.G5kReuseRefEnvironment {
+		return fmt.Errorf("You can't redeploy the node when reusing the Grid'5000 environment")
+	}
+
+	if err := d.deployImageToNode(); err != nil {
+		return err
+	}
+
+	if err := d.storeCachedPowerState("on"); err != nil {
+		log.Debugf("%s", err.Error())
+	}
+
+	if err := d.waitUntilNodeIsReachable(g5kNodeBootTimeout); err != nil {
+		return err
+	}
+
+	if err := d.applyUserData(); err != nil {
+		return err
+	}
+
+	log.Warnf("The node has been redeployed, please run 'docker-machine provision %s' to install Docker again", d.GetMachineName())
+	return nil
 }
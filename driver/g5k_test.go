@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/Spirals-Team/docker-machine-driver-g5k/api"
+)
+
+func TestGetJobTerminationCause(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		want   string
+	}{
+		{"no event", nil, jobTerminationUnknown},
+		{"unrelated event", []string{"SWITCH_INTO_TERMINATE_STATE"}, jobTerminationUnknown},
+		{"walltime", []string{"WALLTIME"}, jobTerminationWalltime},
+		{"user kill", []string{"FRAG_JOB_REQUEST"}, jobTerminationUserKill},
+		{"besteffort kill", []string{"BESTEFFORT_KILL"}, jobTerminationPreempted},
+		{"node reboot", []string{"PING_CHECKER_NODE_SUSPECTED"}, jobTerminationNodeReboot},
+		{"node reboot at the end of the job", []string{"PING_CHECKER_NODE_SUSPECTED_END_JOB"}, jobTerminationNodeReboot},
+		{"besteffort kill after the kill request", []string{"FRAG_JOB_REQUEST", "BESTEFFORT_KILL"}, jobTerminationPreempted},
+		{"besteffort kill before the kill request", []string{"BESTEFFORT_KILL", "FRAG_JOB_REQUEST"}, jobTerminationPreempted},
+		{"walltime after the kill request", []string{"FRAG_JOB_REQUEST", "WALLTIME"}, jobTerminationWalltime},
+		{"walltime before the kill request", []string{"WALLTIME", "FRAG_JOB_REQUEST"}, jobTerminationWalltime},
+		{"besteffort kill after the walltime", []string{"WALLTIME", "BESTEFFORT_KILL"}, jobTerminationPreempted},
+		{"kill request after a node reboot", []string{"PING_CHECKER_NODE_SUSPECTED", "FRAG_JOB_REQUEST"}, jobTerminationUserKill},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job := &api.Job{}
+			for _, event := range test.events {
+				job.Events = append(job.Events, api.JobEvent{Type: event})
+			}
+
+			if got := getJobTerminationCause(job); got != test.want {
+				t.Errorf("getJobTerminationCause(%v) = '%s', want '%s'", test.events, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckJobReplacement(t *testing.T) {
+	tests := []struct {
+		name              string
+		ownershipRecorded bool
+		submittedByDriver bool
+		recreateOnReboot  bool
+		event             string
+		wantErr           bool
+	}{
+		{"job submitted by the driver", true, true, false, "WALLTIME", false},
+		{"job given by the user", true, false, false, "WALLTIME", true},
+		{"machine created by a previous version", false, false, false, "WALLTIME", true},
+		{"node reboot", true, true, false, "PING_CHECKER_NODE_SUSPECTED", true},
+		{"node reboot with recreation", true, true, true, "PING_CHECKER_NODE_SUSPECTED", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := NewDriver()
+			d.G5kJobID = 1234567
+			d.G5kJobOwnershipRecorded = test.ownershipRecorded
+			d.G5kJobSubmittedByDriver = test.submittedByDriver
+			d.G5kReuseRefEnvironment = true
+			d.G5kRecreateOnReboot = test.recreateOnReboot
+
+			job := &api.Job{UID: 1234567, State: "terminated", Events: []api.JobEvent{{Type: test.event}}}
+			if err := d.checkJobReplacement(job); (err != nil) != test.wantErr {
+				t.Errorf("checkJobReplacement() = %v, want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package driver
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLockFile take an exclusive lock on the file without waiting, the lock is released by the system if the process crashes
+func tryLockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+// unlockFile release the lock taken on the file
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
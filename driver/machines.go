@@ -0,0 +1,94 @@
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// JobNamePrefix is the prefix of the name of the jobs submitted by the driver, the store identifier and the machine name follow it
+const JobNamePrefix string = "docker-machine-g5k_"
+
+// MachineJob represents the job used by a machine of the docker-machine store
+type MachineJob struct {
+	Machine string `json:"machine"`
+	Site    string `json:"site"`
+	JobID   int    `json:"job_id"`
+}
+
+// machineConfig represents the part of the machine configuration file used to find its job
+type machineConfig struct {
+	DriverName string
+	Driver     struct {
+		G5kSite  string
+		G5kJobID int
+	}
+}
+
+// GetStoreID returns the identifier of the docker-machine store, a short hash of its absolute path
+func GetStoreID(storePath string) string {
+	if absPath, err := filepath.Abs(storePath); err == nil {
+		storePath = absPath
+	}
+
+	hash := sha256.Sum256([]byte(filepath.Clean(storePath)))
+	return hex.EncodeToString(hash[:4])
+}
+
+// GetJobName returns the name of the jobs submitted by the driver for the machine of the docker-machine store
+func GetJobName(storePath string, machine string) string {
+	return JobNamePrefix + GetStoreID(storePath) + "_" + machine
+}
+
+// ParseJobName returns the machine name from the name of a job, and false if the job was not submitted by the driver for a machine of the docker-machine store
+func ParseJobName(storePath string, name string) (string, bool) {
+	prefix := JobNamePrefix + GetStoreID(storePath) + "_"
+	if !strings.HasPrefix(name, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(name, prefix), true
+}
+
+// LoadMachineJobs returns the jobs used by the Grid'5000 machines of the given docker-machine store
+func LoadMachineJobs(storePath string) ([]MachineJob, error) {
+	jobs := []MachineJob{}
+
+	machinesPath := filepath.Join(storePath, "machines")
+	entries, err := ioutil.ReadDir(machinesPath)
+	if os.IsNotExist(err) {
+		return jobs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list the machines of the store: %s", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(machinesPath, entry.Name(), "config.json"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load the configuration of the '%s' machine: %s", entry.Name(), err)
+		}
+
+		var config machineConfig
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("Failed to parse the configuration of the '%s' machine: %s", entry.Name(), err)
+		}
+
+		if config.DriverName == "g5k" && config.Driver.G5kJobID != 0 {
+			jobs = append(jobs, MachineJob{Machine: entry.Name(), Site: config.Driver.G5kSite, JobID: config.Driver.G5kJobID})
+		}
+	}
+
+	return jobs, nil
+}
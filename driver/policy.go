@@ -0,0 +1,248 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// Hours of the day period of the Grid'5000 usage policy (on working days), the rest of the time is the night period
+const (
+	g5kDayStartHour int = 9
+	g5kDayEndHour   int = g5kNightStartHour
+)
+
+// g5kDayWalltimeWarning is the walltime above which a job started during the day period should be run at night instead
+const g5kDayWalltimeWarning time.Duration = 2 * time.Hour
+
+// g5kQueueMaxWalltime stores the maximum walltime of the jobs of the queues which have one
+var g5kQueueMaxWalltime = map[string]time.Duration{
+	"production": 168 * time.Hour,
+}
+
+// Parameters of the search of a compliant start time
+const (
+	policySuggestionStep    time.Duration = 15 * time.Minute
+	policySuggestionHorizon time.Duration = 14 * 24 * time.Hour
+)
+
+// policyJob represents the job checked against the usage policy
+type policyJob struct {
+	Site           string
+	Queue          string
+	Types          []string
+	Walltime       time.Duration
+	StartTime      time.Time
+	ExoticClusters []string
+}
+
+// policyViolation represents a rule of the usage policy violated by a job, a refused violation prevents the submission
+type policyViolation struct {
+	Refused bool
+	Message string
+}
+
+// g5kFixedPublicHolidays are the French public holidays having a fixed date, they are treated as weekend days by the usage policy
+var g5kFixedPublicHolidays = []struct {
+	Month time.Month
+	Day   int
+}{
+	{time.January, 1},   // New Year's Day
+	{time.May, 1},       // Labour Day
+	{time.May, 8},       // Victory in Europe Day
+	{time.July, 14},     // Bastille Day
+	{time.August, 15},   // Assumption
+	{time.November, 1},  // All Saints' Day
+	{time.November, 11}, // Armistice Day
+	{time.December, 25}, // Christmas
+}
+
+// g5kEasterPublicHolidays are the offsets (in days) from Easter Sunday of the French public holidays depending on Easter
+var g5kEasterPublicHolidays = []int{
+	1,  // Easter Monday
+	39, // Ascension Day
+	50, // Whit Monday
+}
+
+// getEasterSunday returns the date of Easter Sunday of the given year (anonymous Gregorian algorithm)
+func getEasterSunday(year int) (time.Month, int) {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := (19*a + b - b/4 - (b-(b+8)/25+1)/3 + 15) % 30
+	e := (32 + 2*(b%4) + 2*(c/4) - d - c%4) % 7
+	f := d + e - 7*((a+11*d+22*e)/451) + 114
+
+	return time.Month(f / 31), f%31 + 1
+}
+
+// isPublicHoliday returns true if the given time is a French public holiday (in the Grid'5000 timezone)
+func isPublicHoliday(t time.Time) bool {
+	year, month, day := t.In(getG5kLocation()).Date()
+
+	for _, holiday := range g5kFixedPublicHolidays {
+		if holiday.Month == month && holiday.Day == day {
+			return true
+		}
+	}
+
+	easterMonth, easterDay := getEasterSunday(year)
+	easter := time.Date(year, easterMonth, easterDay, 0, 0, 0, 0, time.UTC)
+	for _, offset := range g5kEasterPublicHolidays {
+		if holiday := easter.AddDate(0, 0, offset); holiday.Month() == month && holiday.Day() == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isWorkingDay returns true if the given time is on a working day, the weekends and the public holidays are not
+func isWorkingDay(t time.Time) bool {
+	t = t.In(getG5kLocation())
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday && !isPublicHoliday(t)
+}
+
+// isDayPeriod returns true if the given time is in the day period of the usage policy
+func isDayPeriod(t time.Time) bool {
+	t = t.In(getG5kLocation())
+	return isWorkingDay(t) && t.Hour() >= g5kDayStartHour && t.Hour() < g5kDayEndHour
+}
+
+// getNextDayPeriodStart returns the start of the next day period strictly after the given time
+func getNextDayPeriodStart(t time.Time) time.Time {
+	location := getG5kLocation()
+	year, month, day := t.In(location).Date()
+
+	dayStart := time.Date(year, month, day, g5kDayStartHour, 0, 0, 0, location)
+	for !dayStart.After(t) || !isWorkingDay(dayStart) {
+		dayStart = dayStart.AddDate(0, 0, 1)
+	}
+
+	return dayStart
+}
+
+// checkUsagePolicy returns the rules of the usage policy violated by the job
+func checkUsagePolicy(job policyJob) []policyViolation {
+	violations := []policyViolation{}
+
+	if maxWalltime, ok := g5kQueueMaxWalltime[job.Queue]; ok && job.Walltime > maxWalltime {
+		violations = append(violations, policyViolation{Refused: true, Message: fmt.Sprintf("The walltime of the jobs of the '%s' queue is limited to %s", job.Queue, FormatWalltime(maxWalltime))})
+	}
+
+	if len(job.ExoticClusters) > 0 && !ArrayContainsString(job.Types, "exotic") {
+		violations = append(violations, policyViolation{Refused: true, Message: fmt.Sprintf("The exotic clusters (%s) of the '%s' site can only be used by jobs of the 'exotic' type", strings.Join(job.ExoticClusters, ", "), job.Site)})
+	}
+
+	// the day and night periods only apply to the default queue
+	if job.Queue != "" && job.Queue != "default" {
+		return violations
+	}
+
+	// the jobs must not overlap a day period they did not start in
+	if dayStart := getNextDayPeriodStart(job.StartTime); job.StartTime.Add(job.Walltime).After(dayStart) {
+		violations = append(violations, policyViolation{Refused: true, Message: fmt.Sprintf("The job would still be running during the day period starting at %s, the jobs must end before the start of the next working day", FormatReservationDate(dayStart))})
+	}
+
+	if isDayPeriod(job.StartTime) && job.Walltime > g5kDayWalltimeWarning {
+		violations = append(violations, policyViolation{Refused: false, Message: fmt.Sprintf("The jobs longer than %s should be run during the night or the weekend", FormatWalltime(g5kDayWalltimeWarning))})
+	}
+
+	return violations
+}
+
+// isRefusedByUsagePolicy returns true if one of the violations prevents the submission
+func isRefusedByUsagePolicy(violations []policyViolation) bool {
+	for _, violation := range violations {
+		if violation.Refused {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestCompliantStartTime returns the nearest start time (not before the given time) for which the job is not refused by the usage policy
+func suggestCompliantStartTime(job policyJob, notBefore time.Time) (time.Time, bool) {
+	requested := job.StartTime.Truncate(policySuggestionStep)
+
+	for offset := time.Duration(0); offset <= policySuggestionHorizon; offset += policySuggestionStep {
+		// try the earlier start time first, at the same distance of the requested start time
+		for _, candidate := range []time.Time{requested.Add(-offset), requested.Add(offset)} {
+			if candidate.Before(notBefore) {
+				continue
+			}
+
+			job.StartTime = candidate
+			if !isRefusedByUsagePolicy(checkUsagePolicy(job)) {
+				return candidate, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// checkJobUsagePolicy check the job of the machine against the usage policy, the violations are either logged as warnings or refuse the submission
+func (d *Driver) checkJobUsagePolicy() error {
+	now := time.Now()
+
+	walltime, err := ParseWalltime(d.G5kWalltime)
+	if err != nil {
+		return err
+	}
+
+	startTime := now
+	if d.G5kJobStartTime != "" {
+		if startTime, err = ParseReservationDate(d.G5kJobStartTime, now); err != nil {
+			return err
+		}
+	}
+
+	_, jobTypes := d.getJobCommandAndTypes()
+	job := policyJob{
+		Site:      d.G5kSite,
+		Queue:     d.G5kJobQueue,
+		Types:     jobTypes,
+		Walltime:  walltime,
+		StartTime: startTime,
+	}
+
+	// the exotic clusters are only checked when the properties select a cluster
+	if clusters := propertiesClusterRegexp.FindAllStringSubmatch(d.G5kResourceProperties, -1); len(clusters) > 0 {
+		siteClusters, err := d.g5kAPI.GetClusters()
+		if err != nil {
+			log.Warnf("Failed to retrieve the clusters of the '%s' site, the exotic clusters are not checked: %s", d.G5kSite, err)
+		}
+
+		for _, siteCluster := range siteClusters {
+			for _, cluster := range clusters {
+				if siteCluster.Exotic && siteCluster.UID == cluster[1] {
+					job.ExoticClusters = ArrayRemoveDuplicate(append(job.ExoticClusters, siteCluster.UID))
+				}
+			}
+		}
+	}
+
+	violations := checkUsagePolicy(job)
+	messages := []string{}
+	for _, violation := range violations {
+		if violation.Refused && !d.G5kIgnoreUsagePolicy {
+			messages = append(messages, violation.Message)
+		} else {
+			log.Warnf("Usage policy: %s", violation.Message)
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("The job violates the Grid'5000 usage policy:\n- %s", strings.Join(messages, "\n- "))
+	if suggestion, ok := suggestCompliantStartTime(job, now); ok {
+		message += fmt.Sprintf("\nThe nearest compliant start time is '%s', use it with the '--g5k-make-resource-reservation' flag", FormatReservationDate(suggestion))
+	}
+
+	return fmt.Errorf("%s", message)
+}
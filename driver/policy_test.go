@@ -0,0 +1,142 @@
+package driver
+
+import (
+	"testing"
+	"time"
+)
+
+// policyDate returns the given date in the Grid'5000 timezone
+func policyDate(year int, month time.Month, day int, hour int, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, getG5kLocation())
+}
+
+func TestGetEasterSunday(t *testing.T) {
+	tests := []struct {
+		year  int
+		month time.Month
+		day   int
+	}{
+		{2019, time.April, 21},
+		{2024, time.March, 31},
+		{2025, time.April, 20},
+		{2026, time.April, 5},
+		{2038, time.April, 25},
+	}
+
+	for _, tt := range tests {
+		if month, day := getEasterSunday(tt.year); month != tt.month || day != tt.day {
+			t.Errorf("getEasterSunday(%d) = %s %d, want %s %d", tt.year, month, day, tt.month, tt.day)
+		}
+	}
+}
+
+func TestIsWorkingDay(t *testing.T) {
+	tests := []struct {
+		date time.Time
+		want bool
+	}{
+		{policyDate(2024, time.March, 12, 10, 0), true},    // Tuesday
+		{policyDate(2024, time.March, 16, 10, 0), false},   // Saturday
+		{policyDate(2024, time.March, 17, 10, 0), false},   // Sunday
+		{policyDate(2024, time.March, 29, 10, 0), true},    // Good Friday is not a public holiday
+		{policyDate(2024, time.April, 1, 10, 0), false},    // Easter Monday
+		{policyDate(2024, time.May, 1, 10, 0), false},      // Labour Day
+		{policyDate(2024, time.May, 9, 10, 0), false},      // Ascension Day
+		{policyDate(2024, time.May, 20, 10, 0), false},     // Whit Monday
+		{policyDate(2024, time.July, 14, 10, 0), false},    // Bastille Day (Sunday)
+		{policyDate(2025, time.June, 9, 10, 0), false},     // Whit Monday
+		{policyDate(2025, time.December, 25, 0, 0), false}, // Christmas
+		{policyDate(2025, time.December, 26, 0, 0), true},
+		// the days are computed in the Grid'5000 timezone (23:30 UTC is already the 1st of January in Paris)
+		{time.Date(2025, time.December, 31, 23, 30, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		if got := isWorkingDay(tt.date); got != tt.want {
+			t.Errorf("isWorkingDay(%s) = %t, want %t", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestIsDayPeriod(t *testing.T) {
+	tests := []struct {
+		date time.Time
+		want bool
+	}{
+		{policyDate(2024, time.March, 12, 8, 59), false},
+		{policyDate(2024, time.March, 12, 9, 0), true},
+		{policyDate(2024, time.March, 12, 18, 59), true},
+		{policyDate(2024, time.March, 12, 19, 0), false},
+		{policyDate(2024, time.March, 16, 12, 0), false}, // Saturday
+		{policyDate(2024, time.April, 1, 12, 0), false},  // Easter Monday
+	}
+
+	for _, tt := range tests {
+		if got := isDayPeriod(tt.date); got != tt.want {
+			t.Errorf("isDayPeriod(%s) = %t, want %t", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestCheckUsagePolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		job        policyJob
+		refused    bool
+		violations int
+	}{
+		{"short job at the start of the day", policyJob{Walltime: time.Hour, StartTime: policyDate(2024, time.March, 12, 9, 0)}, false, 0},
+		{"long job during the day", policyJob{Walltime: 3 * time.Hour, StartTime: policyDate(2024, time.March, 12, 9, 0)}, false, 1},
+		{"long job at the end of the day", policyJob{Walltime: 14*time.Hour + time.Minute, StartTime: policyDate(2024, time.March, 12, 18, 59)}, false, 1},
+		{"job ending at the start of the day", policyJob{Walltime: time.Hour, StartTime: policyDate(2024, time.March, 12, 8, 0)}, false, 0},
+		{"job started just before the day", policyJob{Walltime: 2 * time.Hour, StartTime: policyDate(2024, time.March, 12, 8, 59)}, true, 1},
+		{"whole night", policyJob{Walltime: 14 * time.Hour, StartTime: policyDate(2024, time.March, 12, 19, 0)}, false, 0},
+		{"night overlapping the next day", policyJob{Walltime: 14*time.Hour + time.Second, StartTime: policyDate(2024, time.March, 12, 19, 0)}, true, 1},
+		{"whole weekend", policyJob{Walltime: 62 * time.Hour, StartTime: policyDate(2024, time.March, 15, 19, 0)}, false, 0},
+		{"weekend overlapping monday", policyJob{Walltime: 62*time.Hour + time.Minute, StartTime: policyDate(2024, time.March, 15, 19, 0)}, true, 1},
+		{"long job during the weekend", policyJob{Walltime: 3 * time.Hour, StartTime: policyDate(2024, time.March, 16, 10, 0)}, false, 0},
+		{"long job on a public holiday", policyJob{Walltime: 3 * time.Hour, StartTime: policyDate(2024, time.April, 1, 10, 0)}, false, 0},
+		// the summer time starts during the easter weekend of 2024, it lasts one hour less
+		{"easter weekend", policyJob{Walltime: 85 * time.Hour, StartTime: policyDate(2024, time.March, 29, 19, 0)}, false, 0},
+		{"easter weekend overlapping tuesday", policyJob{Walltime: 85*time.Hour + time.Minute, StartTime: policyDate(2024, time.March, 29, 19, 0)}, true, 1},
+		{"public holidays in a row", policyJob{Walltime: 62 * time.Hour, StartTime: policyDate(2024, time.May, 7, 19, 0)}, false, 0},
+		{"default queue by name", policyJob{Queue: "default", Walltime: 2 * time.Hour, StartTime: policyDate(2024, time.March, 12, 8, 59)}, true, 1},
+		{"production queue", policyJob{Queue: "production", Walltime: 168 * time.Hour, StartTime: policyDate(2024, time.March, 12, 10, 0)}, false, 0},
+		{"production queue over the limit", policyJob{Queue: "production", Walltime: 168*time.Hour + time.Second, StartTime: policyDate(2024, time.March, 12, 10, 0)}, true, 1},
+		{"besteffort queue", policyJob{Queue: "besteffort", Walltime: 48 * time.Hour, StartTime: policyDate(2024, time.March, 12, 10, 0)}, false, 0},
+		{"exotic cluster", policyJob{Site: "lyon", ExoticClusters: []string{"pyxis"}, Walltime: time.Hour, StartTime: policyDate(2024, time.March, 12, 10, 0)}, true, 1},
+		{"exotic cluster with the exotic type", policyJob{Site: "lyon", Types: []string{"deploy", "exotic"}, ExoticClusters: []string{"pyxis"}, Walltime: time.Hour, StartTime: policyDate(2024, time.March, 12, 10, 0)}, false, 0},
+	}
+
+	for _, tt := range tests {
+		violations := checkUsagePolicy(tt.job)
+		if refused := isRefusedByUsagePolicy(violations); refused != tt.refused || len(violations) != tt.violations {
+			t.Errorf("checkUsagePolicy() of the %s = %+v, want refused: %t with %d violation(s)", tt.name, violations, tt.refused, tt.violations)
+		}
+	}
+}
+
+func TestSuggestCompliantStartTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		job       policyJob
+		notBefore time.Time
+		want      time.Time
+		found     bool
+	}{
+		{"compliant request", policyJob{Walltime: time.Hour, StartTime: policyDate(2024, time.March, 12, 10, 0)}, policyDate(2024, time.March, 12, 10, 0), policyDate(2024, time.March, 12, 10, 0), true},
+		{"earlier start", policyJob{Walltime: 24 * time.Hour, StartTime: policyDate(2024, time.March, 12, 10, 0)}, policyDate(2024, time.March, 12, 8, 0), policyDate(2024, time.March, 12, 9, 0), true},
+		{"later start", policyJob{Walltime: 24 * time.Hour, StartTime: policyDate(2024, time.March, 12, 10, 0)}, policyDate(2024, time.March, 12, 10, 0), policyDate(2024, time.March, 13, 9, 0), true},
+		{"start after the day", policyJob{Walltime: 3 * time.Hour, StartTime: policyDate(2024, time.March, 12, 7, 0)}, policyDate(2024, time.March, 12, 7, 0), policyDate(2024, time.March, 12, 9, 0), true},
+		{"rounded request", policyJob{Walltime: 14 * time.Hour, StartTime: policyDate(2024, time.March, 12, 18, 50)}, policyDate(2024, time.March, 12, 18, 50), policyDate(2024, time.March, 12, 19, 0), true},
+		{"public holiday", policyJob{Walltime: 24 * time.Hour, StartTime: policyDate(2024, time.April, 30, 10, 0)}, policyDate(2024, time.April, 30, 10, 0), policyDate(2024, time.April, 30, 10, 0), true},
+		{"walltime over the queue limit", policyJob{Queue: "production", Walltime: 200 * time.Hour, StartTime: policyDate(2024, time.March, 12, 10, 0)}, policyDate(2024, time.March, 12, 10, 0), time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, found := suggestCompliantStartTime(tt.job, tt.notBefore)
+		if found != tt.found || !got.Equal(tt.want) {
+			t.Errorf("suggestCompliantStartTime() of the %s = %s (found: %t), want %s (found: %t)", tt.name, got, found, tt.want, tt.found)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// States of the reservations of the registry
+const (
+	ReservationStatePending   string = "pending"
+	ReservationStateReserved  string = "reserved"
+	ReservationStateUsed      string = "used"
+	ReservationStateCancelled string = "cancelled"
+)
+
+// Reservation represents a resource reservation made through the driver
+type Reservation struct {
+	Name                string    `json:"name"`
+	Site                string    `json:"site"`
+	JobID               int       `json:"job_id"`
+	StartTime           string    `json:"start_time"`
+	Walltime            string    `json:"walltime"`
+	Queue               string    `json:"queue"`
+	Project             string    `json:"project,omitempty"`
+	Properties          string    `json:"properties,omitempty"`
+	ReuseRefEnvironment bool      `json:"reuse_ref_environment"`
+	Nodes               []string  `json:"nodes,omitempty"`
+	Machines            []string  `json:"machines,omitempty"`
+	Pending             bool      `json:"pending"`
+	Cancelled           bool      `json:"cancelled"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// State returns the state of the reservation in the registry
+func (r *Reservation) State() string {
+	switch {
+	case r.Cancelled:
+		return ReservationStateCancelled
+	case len(r.Machines) > 0:
+		return ReservationStateUsed
+	case r.Pending:
+		return ReservationStatePending
+	default:
+		return ReservationStateReserved
+	}
+}
+
+// ReservationRegistry stores the reservations made through the driver in the driver store
+type ReservationRegistry struct {
+	path string
+}
+
+// g5kRegistryLockTimeout is the maximum duration a process waits for the registry updated by another process
+const g5kRegistryLockTimeout time.Duration = 30 * time.Second
+
+// NewReservationRegistry returns the registry of the given docker-machine store
+func NewReservationRegistry(storePath string) *ReservationRegistry {
+	return &ReservationRegistry{path: filepath.Join(storePath, "g5k", "reservations.json")}
+}
+
+// Load returns the reservations of the registry
+func (r *ReservationRegistry) Load() ([]Reservation, error) {
+	reservations := []Reservation{}
+
+	data, err := ioutil.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return reservations, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the reservations registry: %s", err)
+	}
+
+	if err := json.Unmarshal(data, &reservations); err != nil {
+		return nil, fmt.Errorf("Failed to parse the reservations registry: %s", err)
+	}
+
+	return reservations, nil
+}
+
+// Save store the reservations in the registry
+// The file is replaced at once, so it is never read partially written by another process
+func (r *ReservationRegistry) Save(reservations []Reservation) error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("Failed to create the driver storage directory: %s", err)
+	}
+
+	data, err := json.MarshalIndent(reservations, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to serialize the reservations registry: %s", err)
+	}
+
+	if err := storeCacheFile(r.path, string(data)); err != nil {
+		return fmt.Errorf("Failed to save the reservations registry: %s", err)
+	}
+
+	return nil
+}
+
+// modify apply the given function to the reservations of the registry and save them if requested, while holding the lock of the registry
+// The concurrent updates (ex: two machine creations, or a creation and a cancellation) would otherwise lose each other's changes
+func (r *ReservationRegistry) modify(modify func([]Reservation) ([]Reservation, bool)) error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("Failed to create the driver storage directory: %s", err)
+	}
+
+	unlock, err := lockFile(r.path+".lock", g5kRegistryLockTimeout)
+	if err != nil {
+		return fmt.Errorf("Failed to lock the reservations registry: %s", err)
+	}
+	defer unlock()
+
+	reservations, err := r.Load()
+	if err != nil {
+		return err
+	}
+
+	reservations, save := modify(reservations)
+	if !save {
+		return nil
+	}
+
+	return r.Save(reservations)
+}
+
+// Add append the reservation to the registry
+func (r *ReservationRegistry) Add(reservation Reservation) error {
+	return r.modify(func(reservations []Reservation) ([]Reservation, bool) {
+		return append(reservations, reservation), true
+	})
+}
+
+// Find returns the most recent reservation of the site (any site if empty) with the given name or job ID, nil if there is none
+func (r *ReservationRegistry) Find(site string, ref string) (*Reservation, error) {
+	reservations, err := r.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(reservations) - 1; i >= 0; i-- {
+		if reservations[i].matches(site, ref) {
+			return &reservations[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Update apply the given function to the most recent reservation of the site with the given name or job ID, and returns false if there is none
+func (r *ReservationRegistry) Update(site string, ref string, update func(*Reservation)) (bool, error) {
+	found := false
+	err := r.modify(func(reservations []Reservation) ([]Reservation, bool) {
+		for i := len(reservations) - 1; i >= 0; i-- {
+			if reservations[i].matches(site, ref) {
+				update(&reservations[i])
+				found = true
+				break
+			}
+		}
+		return reservations, found
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return found, nil
+}
+
+// matches returns true if the reservation is on the site (any site if empty) and has the given name or job ID
+func (r *Reservation) matches(site string, ref string) bool {
+	if site != "" && r.Site != site {
+		return false
+	}
+	return r.Name == ref || strconv.Itoa(r.JobID) == ref
+}
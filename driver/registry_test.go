@@ -0,0 +1,57 @@
+package driver
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestReservationRegistryConcurrentUpdates(t *testing.T) {
+	registry := NewReservationRegistry(t.TempDir())
+
+	const count = 20
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := registry.Add(Reservation{Site: "lille", JobID: 1000 + i}); err != nil {
+				t.Errorf("Add: unexpected error: %s", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	reservations, err := registry.Load()
+	if err != nil {
+		t.Fatalf("Load: unexpected error: %s", err)
+	}
+	if len(reservations) != count {
+		t.Fatalf("expected %d reservations, got %d", count, len(reservations))
+	}
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			found, err := registry.Update("lille", fmt.Sprint(1000+i), func(r *Reservation) { r.Pending = true })
+			if err != nil || !found {
+				t.Errorf("Update %d: found=%v, err=%v", 1000+i, found, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if reservations, err = registry.Load(); err != nil {
+		t.Fatalf("Load: unexpected error: %s", err)
+	}
+	for _, reservation := range reservations {
+		if !reservation.Pending {
+			t.Errorf("the update of the job %d was lost", reservation.JobID)
+		}
+	}
+
+	if found, err := registry.Update("nancy", "1000", func(r *Reservation) {}); err != nil || found {
+		t.Errorf("Update of another site: found=%v, err=%v", found, err)
+	}
+}
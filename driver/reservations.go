@@ -0,0 +1,81 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Modes of the machine creation when making a resource reservation
+const (
+	reservationModeAbort  string = "abort"
+	reservationModeWait   string = "wait"
+	reservationModeRecord string = "record"
+)
+
+// getReservationRegistry returns the registry of the reservations made through the driver
+func (d *Driver) getReservationRegistry() *ReservationRegistry {
+	return NewReservationRegistry(d.StorePath)
+}
+
+// findPendingReservation returns the pending reservation recorded with the given name, nil if there is none
+func (d *Driver) findPendingReservation(name string) (*Reservation, error) {
+	reservation, err := d.getReservationRegistry().Find(d.G5kSite, name)
+	if err != nil || reservation == nil || reservation.Name != name || reservation.State() != ReservationStatePending {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// recordReservation record the reservation of the machine in the registry, under the name of the machine
+func (d *Driver) recordReservation(pending bool) error {
+	return d.getReservationRegistry().Add(Reservation{
+		Name:                d.GetMachineName(),
+		Site:                d.G5kSite,
+		JobID:               d.G5kJobID,
+		StartTime:           d.G5kJobStartTime,
+		Walltime:            d.G5kWalltime,
+		Queue:               d.G5kJobQueue,
+		Project:             d.G5kProject,
+		Properties:          d.G5kResourceProperties,
+		ReuseRefEnvironment: d.G5kReuseRefEnvironment,
+		Pending:             pending,
+		CreatedAt:           time.Now(),
+	})
+}
+
+// usePendingReservation configure the driver to create the machine with the job of the given pending reservation
+func (d *Driver) usePendingReservation(name string) error {
+	reservation, err := d.findPendingReservation(name)
+	if err != nil {
+		return err
+	}
+	if reservation == nil {
+		return fmt.Errorf("There is no pending reservation named '%s' on the '%s' site", name, d.G5kSite)
+	}
+
+	// the job command and types of the reservation depend on the reuse of the reference environment
+	if reservation.ReuseRefEnvironment != d.G5kReuseRefEnvironment {
+		return fmt.Errorf("The pending reservation '%s' was made with a different reference environment reuse setting (reuse: %t)", name, reservation.ReuseRefEnvironment)
+	}
+
+	d.G5kJobID = reservation.JobID
+	return nil
+}
+
+// registerReservationMachine record the machine and the nodes of the job in the registry, if the job is a reservation made through the driver
+func (d *Driver) registerReservationMachine() error {
+	job, err := d.g5kAPI.GetJob(d.G5kJobID)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.getReservationRegistry().Update(d.G5kSite, strconv.Itoa(d.G5kJobID), func(reservation *Reservation) {
+		reservation.Nodes = job.Nodes
+		reservation.Machines = ArrayRemoveDuplicate(append(reservation.Machines, d.GetMachineName()))
+		reservation.Pending = false
+	})
+
+	return err
+}
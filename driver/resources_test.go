@@ -0,0 +1,43 @@
+package driver
+
+import "testing"
+
+func TestNewResourceRequest(t *testing.T) {
+	tests := []struct {
+		nodes    int
+		gpus     int
+		topology string
+		subnets  string
+		want     string
+		wantErr  bool
+	}{
+		{0, 0, "", "", "nodes=1,walltime=1:00:00", false},
+		{2, 1, "cluster=1", "", "{gpu_count >= 1}/cluster=1/nodes=2,walltime=1:00:00", false},
+		{1, 0, "switch=1", "slash_22=1", "switch=1/nodes=1+slash_22=1,walltime=1:00:00", false},
+		{1, 0, "", "slash_22=1/slash_18=1", "nodes=1+slash_22=1+slash_18=1,walltime=1:00:00", false},
+		{1, 0, "nodes=1", "", "", true},
+		{1, 0, "core=8", "", "", true},
+		{1, 0, "gpu=1", "", "", true},
+		{1, 0, "switch", "", "", true},
+		{1, 0, "", "core=8", "", true},
+		{1, 0, "", "slash_22", "", true},
+	}
+
+	for _, tt := range tests {
+		d := NewDriver()
+		d.G5kWalltime = "1:00:00"
+		d.G5kNodes = tt.nodes
+		d.G5kGPUs = tt.gpus
+		d.G5kTopology = tt.topology
+		d.G5kSubnets = tt.subnets
+
+		resources, err := d.newResourceRequest()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("newResourceRequest() with topology %q and subnets %q error = %v, want error: %t", tt.topology, tt.subnets, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && resources.String() != tt.want {
+			t.Errorf("newResourceRequest() with topology %q and subnets %q = %q, want %q", tt.topology, tt.subnets, resources, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	// embed the timezone database, the Grid'5000 timezone must be available on every platform
+	_ "time/tzdata"
+)
+
+// g5kTimezone is the timezone used by OAR for the reservation dates
+const g5kTimezone string = "Europe/Paris"
+
+// g5kNightStartHour is the hour at which the night period of the Grid'5000 usage policy starts
+const g5kNightStartHour int = 19
+
+// reservationDateLayouts are the accepted layouts of the reservation dates in the Grid'5000 timezone
+var reservationDateLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+// walltimeDaysRegexp matches a duration with a day suffix (ex: "1d", "2d12h")
+var walltimeDaysRegexp = regexp.MustCompile(`^(\d+)d(.*)$`)
+
+// numberRegexp matches a positive integer (ex: an UNIX timestamp)
+var numberRegexp = regexp.MustCompile(`^\d+$`)
+
+// getG5kLocation returns the location of the Grid'5000 timezone
+func getG5kLocation() *time.Location {
+	location, err := time.LoadLocation(g5kTimezone)
+	if err != nil {
+		// the timezone database is embedded, this should never happen
+		panic(fmt.Sprintf("Failed to load the '%s' timezone: %s", g5kTimezone, err))
+	}
+	return location
+}
+
+// parseDuration parse a positive Go duration with an optional day suffix (ex: "90m", "1d", "2d12h")
+func parseDuration(value string) (time.Duration, error) {
+	days := 0
+	if matches := walltimeDaysRegexp.FindStringSubmatch(value); matches != nil {
+		days, _ = strconv.Atoi(matches[1])
+		value = matches[2]
+	}
+
+	duration := time.Duration(0)
+	if value != "" {
+		var err error
+		if duration, err = time.ParseDuration(value); err != nil {
+			return 0, err
+		}
+		if duration < 0 {
+			return 0, fmt.Errorf("The duration '%s' must be positive", value)
+		}
+	}
+
+	return time.Duration(days)*24*time.Hour + duration, nil
+}
+
+// ParseWalltime parse a walltime in the OAR format (HH:MM:SS, HH:MM or HH), as a Go duration or with a day suffix (ex: "1d12h")
+func ParseWalltime(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+
+	var walltime time.Duration
+	if strings.Contains(value, ":") || numberRegexp.MatchString(value) {
+		parts := strings.Split(value, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("The walltime '%s' must have the 'HH:MM:SS' format", value)
+		}
+
+		units := []time.Duration{time.Hour, time.Minute, time.Second}
+		for i, part := range parts {
+			number, err := strconv.Atoi(part)
+			if err != nil || number < 0 || (i > 0 && number >= 60) {
+				return 0, fmt.Errorf("The walltime '%s' must have the 'HH:MM:SS' format", value)
+			}
+			walltime += time.Duration(number) * units[i]
+		}
+	} else {
+		var err error
+		if walltime, err = parseDuration(value); err != nil {
+			return 0, fmt.Errorf("The walltime '%s' must have the 'HH:MM:SS' format or be a duration (ex: '90m', '1d12h')", value)
+		}
+	}
+
+	if walltime <= 0 {
+		return 0, fmt.Errorf("The walltime '%s' must be positive", value)
+	}
+	if walltime%time.Second != 0 {
+		return 0, fmt.Errorf("The walltime '%s' must be a whole number of seconds", value)
+	}
+
+	return walltime, nil
+}
+
+// FormatWalltime returns the walltime in the format expected by OAR (H:MM:SS, the hours can exceed 24)
+func FormatWalltime(walltime time.Duration) string {
+	seconds := int(walltime / time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// ParseReservationDate parse the start date of a reservation relative to the given time, the dates before the given time are refused
+// The accepted formats are RFC3339, 'YYYY-MM-DD HH:MM:SS' in the Grid'5000 timezone, UNIX timestamps, relative durations (ex: "+2h") and "tonight"
+func ParseReservationDate(value string, now time.Time) (time.Time, error) {
+	date, err := parseReservationDate(strings.TrimSpace(value), now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if date.Before(now.Truncate(time.Second)) {
+		return time.Time{}, fmt.Errorf("The reservation date '%s' is in the past", FormatReservationDate(date))
+	}
+
+	return date, nil
+}
+
+// parseReservationDate parse the start date of a reservation in one of the accepted formats, relative to the given time
+func parseReservationDate(value string, now time.Time) (time.Time, error) {
+	location := getG5kLocation()
+
+	switch {
+	case value == "tonight":
+		// the next start of the night period of the usage policy
+		year, month, day := now.In(location).Date()
+		night := time.Date(year, month, day, g5kNightStartHour, 0, 0, 0, location)
+		if !night.After(now) {
+			night = night.AddDate(0, 0, 1)
+		}
+		return night, nil
+
+	case strings.HasPrefix(value, "+"):
+		duration, err := parseDuration(value[1:])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("The relative date '%s' must be a duration (ex: '+2h', '+1d')", value)
+		}
+		return now.Add(duration).Truncate(time.Second), nil
+
+	case numberRegexp.MatchString(value):
+		timestamp, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("The UNIX timestamp '%s' is invalid: %s", value, err)
+		}
+		return time.Unix(timestamp, 0), nil
+	}
+
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+
+	for _, layout := range reservationDateLayouts {
+		if date, err := time.ParseInLocation(layout, value, location); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("The reservation date '%s' must be in the RFC3339 or 'YYYY-MM-DD HH:MM:SS' (%s timezone) format, an UNIX timestamp, a relative duration (ex: '+2h') or 'tonight'", value, g5kTimezone)
+}
+
+// FormatReservationDate returns the date in the format expected by OAR ('YYYY-MM-DD HH:MM:SS' in the Grid'5000 timezone)
+func FormatReservationDate(date time.Time) string {
+	return date.In(getG5kLocation()).Format(reservationDateLayouts[0])
+}
@@ -0,0 +1,116 @@
+package driver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWalltime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"2", 2 * time.Hour, false},
+		{"1:30", 90 * time.Minute, false},
+		{"01:30:15", 90*time.Minute + 15*time.Second, false},
+		{"36:00:00", 36 * time.Hour, false},
+		{" 3:00 ", 3 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"1d", 24 * time.Hour, false},
+		{"2d12h", 60 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"", 0, true},
+		{"0", 0, true},
+		{"0:00:00", 0, true},
+		{"1:60", 0, true},
+		{"1:00:60", 0, true},
+		{"1:00:00:00", 0, true},
+		{"-1:00", 0, true},
+		{"1:aa", 0, true},
+		{"two hours", 0, true},
+		{"1d-2h", 0, true},
+		{"-30m", 0, true},
+		{"1500ms", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseWalltime(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseWalltime(%q) error = %v, want error: %t", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseWalltime(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatWalltime(t *testing.T) {
+	tests := []struct {
+		walltime time.Duration
+		want     string
+	}{
+		{90 * time.Minute, "1:30:00"},
+		{36*time.Hour + 5*time.Second, "36:00:05"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatWalltime(tt.walltime); got != tt.want {
+			t.Errorf("FormatWalltime(%s) = %q, want %q", tt.walltime, got, tt.want)
+		}
+	}
+}
+
+func TestParseReservationDate(t *testing.T) {
+	location := getG5kLocation()
+	// Tuesday 12 March 2024, 15:30 in the Grid'5000 timezone
+	now := time.Date(2024, time.March, 12, 15, 30, 0, 0, location)
+
+	tests := []struct {
+		value   string
+		now     time.Time
+		want    time.Time
+		wantErr bool
+	}{
+		{"2024-03-12 18:00:00", now, time.Date(2024, time.March, 12, 18, 0, 0, 0, location), false},
+		{"2024-03-13 08:15", now, time.Date(2024, time.March, 13, 8, 15, 0, 0, location), false},
+		{"2024-03-12T17:00:00Z", now, time.Date(2024, time.March, 12, 18, 0, 0, 0, location), false},
+		{"1710266400", now, time.Date(2024, time.March, 12, 19, 0, 0, 0, location), false},
+		{"+2h", now, now.Add(2 * time.Hour), false},
+		{"+1d12h", now, now.Add(36 * time.Hour), false},
+		{"tonight", now, time.Date(2024, time.March, 12, 19, 0, 0, 0, location), false},
+		{"tonight", time.Date(2024, time.March, 12, 19, 0, 0, 0, location), time.Date(2024, time.March, 13, 19, 0, 0, 0, location), false},
+		{"tonight", time.Date(2024, time.March, 12, 23, 30, 0, 0, location), time.Date(2024, time.March, 13, 19, 0, 0, 0, location), false},
+		// the summer time starts on 31 March 2024, the night still starts at 19:00 local time
+		{"tonight", time.Date(2024, time.March, 30, 20, 0, 0, 0, location), time.Date(2024, time.March, 31, 19, 0, 0, 0, location), false},
+		{"2024-03-12 15:30:00", now, now, false},
+		{"2024-03-12 15:00:00", now, time.Time{}, true},
+		{"2023-03-12T15:00:00+01:00", now, time.Time{}, true},
+		{"1000000000", now, time.Time{}, true},
+		{"", now, time.Time{}, true},
+		{"tomorrow", now, time.Time{}, true},
+		{"+2 hours", now, time.Time{}, true},
+		{"+-2h", now, time.Time{}, true},
+		{"12/03/2024 18:00", now, time.Time{}, true},
+		{"2024-02-30 18:00:00", now, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseReservationDate(tt.value, tt.now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseReservationDate(%q, %s) error = %v, want error: %t", tt.value, tt.now, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseReservationDate(%q, %s) = %s, want %s", tt.value, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestFormatReservationDate(t *testing.T) {
+	date := time.Date(2024, time.July, 1, 17, 0, 0, 0, time.UTC)
+	if got, want := FormatReservationDate(date), "2024-07-01 19:00:00"; got != want {
+		t.Errorf("FormatReservationDate(%s) = %q, want %q", date, got, want)
+	}
+}
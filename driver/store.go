@@ -1,12 +1,17 @@
 package driver
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/Spirals-Team/docker-machine-driver-g5k/api"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/ssh"
 )
 
@@ -55,3 +60,278 @@ func (d *Driver) loadDriverSSHPublicKey() error {
 	d.DriverSSHPublicKey = strings.TrimSpace(string(sshPublicKey))
 	return nil
 }
+
+// installDriverSSHKey copy the driver SSH key pair to the machine directory
+func (d *Driver) installDriverSSHKey() error {
+	if err := mcnutils.CopyFile(d.getDriverSSHKeyPath(), d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+	if err := mcnutils.CopyFile(d.getDriverSSHKeyPath()+".pub", d.GetSSHKeyPath()+".pub"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// loadCacheFile returns the value stored in the given cache file and the age of the cache
+func loadCacheFile(cachePath string) (string, time.Duration, error) {
+	info, err := os.Stat(cachePath)
+	if err != nil {
+		return "", 0, err
+	}
+
+	value, err := ioutil.ReadFile(cachePath)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return strings.TrimSpace(string(value)), time.Since(info.ModTime()), nil
+}
+
+// storeCacheFile store the value in the given cache file
+// The file is replaced at once, as it can be read at the same time by another plugin process
+func storeCacheFile(cachePath string, value string) error {
+	tmpPath := fmt.Sprintf("%s.%d.tmp", cachePath, os.Getpid())
+	if err := ioutil.WriteFile(tmpPath, []byte(value+"\n"), 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, cachePath)
+}
+
+// getReferenceEnvironmentCachePath returns the path of the file caching the name of the reference environment of the site
+func (d *Driver) getReferenceEnvironmentCachePath() string {
+	return d.resolveDriverStorePath(fmt.Sprintf("reference-environment-%s", d.G5kSite))
+}
+
+// loadCachedReferenceEnvironment returns the cached name of the reference environment of the site and the age of the cache
+func (d *Driver) loadCachedReferenceEnvironment() (string, time.Duration, error) {
+	return loadCacheFile(d.getReferenceEnvironmentCachePath())
+}
+
+// storeCachedReferenceEnvironment store the name of the reference environment of the site in the driver storage directory
+func (d *Driver) storeCachedReferenceEnvironment(name string) error {
+	if err := storeCacheFile(d.getReferenceEnvironmentCachePath(), name); err != nil {
+		return fmt.Errorf("Failed to cache the reference environment: %s", err)
+	}
+
+	return nil
+}
+
+// getPowerStateCachePath returns the path of the file caching the BMC power status of the node
+func (d *Driver) getPowerStateCachePath() string {
+	return d.ResolveStorePath("g5k-power-state")
+}
+
+// loadCachedPowerState returns the cached BMC power status of the node and the age of the cache
+func (d *Driver) loadCachedPowerState() (string, time.Duration, error) {
+	return loadCacheFile(d.getPowerStateCachePath())
+}
+
+// storeCachedPowerState store the BMC power status of the node in the machine directory
+// The pending power status request is abandoned, its result would be older than the stored status
+func (d *Driver) storeCachedPowerState(powerState string) error {
+	d.removeCachedPowerStatusRequest()
+
+	if err := storeCacheFile(d.getPowerStateCachePath(), powerState); err != nil {
+		return fmt.Errorf("Failed to cache the power state: %s", err)
+	}
+
+	return nil
+}
+
+// getPowerStatusRequestCachePath returns the path of the file storing the workflow ID of the pending power status request of the node
+func (d *Driver) getPowerStatusRequestCachePath() string {
+	return d.ResolveStorePath("g5k-power-status-request")
+}
+
+// loadCachedPowerStatusRequest returns the workflow ID of the pending power status request of the node and its age
+func (d *Driver) loadCachedPowerStatusRequest() (string, time.Duration, error) {
+	return loadCacheFile(d.getPowerStatusRequestCachePath())
+}
+
+// storeCachedPowerStatusRequest store the workflow ID of the pending power status request of the node in the machine directory
+func (d *Driver) storeCachedPowerStatusRequest(wid string) error {
+	if err := storeCacheFile(d.getPowerStatusRequestCachePath(), wid); err != nil {
+		return fmt.Errorf("Failed to cache the power status request: %s", err)
+	}
+
+	return nil
+}
+
+// removeCachedPowerStatusRequest remove the pending power status request of the node
+func (d *Driver) removeCachedPowerStatusRequest() {
+	if err := os.Remove(d.getPowerStatusRequestCachePath()); err != nil && !os.IsNotExist(err) {
+		log.Debugf("Failed to remove the power status request: %s", err)
+	}
+}
+
+// saveMachineConfig update the driver configuration stored in the machine config file
+// This is needed when the driver configuration changes during an action that can fail afterwards, as the machine is only saved on success
+func (d *Driver) saveMachineConfig() error {
+	configPath := d.ResolveStorePath("config.json")
+
+	rawConfig, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("Failed to load the machine config: %s", err)
+	}
+
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(rawConfig, &config); err != nil {
+		return fmt.Errorf("Failed to parse the machine config: %s", err)
+	}
+
+	driverConfig, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("Failed to serialize the driver config: %s", err)
+	}
+	config["Driver"] = driverConfig
+
+	rawConfig, err = json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return fmt.Errorf("Failed to serialize the machine config: %s", err)
+	}
+
+	if err := ioutil.WriteFile(configPath, rawConfig, 0600); err != nil {
+		return fmt.Errorf("Failed to save the machine config: %s", err)
+	}
+
+	return nil
+}
+
+// getJobCachePath returns the path of the file caching the job of the machine, it is shared by the plugin processes
+func (d *Driver) getJobCachePath() string {
+	return d.resolveDriverStorePath(fmt.Sprintf("job-%s-%d.json", d.G5kSite, d.G5kJobID))
+}
+
+// loadCachedJob returns the cached job of the machine and the age of the cache
+func (d *Driver) loadCachedJob() (*api.Job, time.Duration, error) {
+	value, cacheAge, err := loadCacheFile(d.getJobCachePath())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var job api.Job
+	if err := json.Unmarshal([]byte(value), &job); err != nil {
+		return nil, 0, err
+	}
+
+	return &job, cacheAge, nil
+}
+
+// storeCachedJob store the job of the machine in the driver storage directory
+func (d *Driver) storeCachedJob(job *api.Job) error {
+	value, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("Failed to serialize the job: %s", err)
+	}
+
+	if err := storeCacheFile(d.getJobCachePath(), string(value)); err != nil {
+		return fmt.Errorf("Failed to cache the job: %s", err)
+	}
+
+	return nil
+}
+
+// removeCachedJob remove the cached job of the machine and the cached jobs of the site, when its state is changed by the driver
+func (d *Driver) removeCachedJob() {
+	for _, cachePath := range []string{d.getJobCachePath(), d.getSiteJobsCachePath()} {
+		if err := os.Remove(cachePath); err != nil && !os.IsNotExist(err) {
+			log.Debugf("Failed to remove the cached job: %s", err)
+		}
+	}
+}
+
+// getSiteJobsCachePath returns the path of the file caching the jobs of the user on the site, it is shared by the plugin processes
+func (d *Driver) getSiteJobsCachePath() string {
+	return d.resolveDriverStorePath(fmt.Sprintf("jobs-%s-%s.json", d.G5kSite, d.G5kUsername))
+}
+
+// loadCachedSiteJobs returns the cached jobs of the user on the site and the age of the cache
+func (d *Driver) loadCachedSiteJobs() ([]api.Job, time.Duration, error) {
+	value, cacheAge, err := loadCacheFile(d.getSiteJobsCachePath())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var jobs []api.Job
+	if err := json.Unmarshal([]byte(value), &jobs); err != nil {
+		return nil, 0, err
+	}
+
+	return jobs, cacheAge, nil
+}
+
+// storeCachedSiteJobs store the jobs of the user on the site in the driver storage directory
+func (d *Driver) storeCachedSiteJobs(jobs []api.Job) error {
+	value, err := json.Marshal(jobs)
+	if err != nil {
+		return fmt.Errorf("Failed to serialize the jobs of the site: %s", err)
+	}
+
+	if err := storeCacheFile(d.getSiteJobsCachePath(), string(value)); err != nil {
+		return fmt.Errorf("Failed to cache the jobs of the site: %s", err)
+	}
+
+	return nil
+}
+
+// tryLock take the lock of the given lock file without waiting and returns the function releasing it
+// The lock file is kept, the lock itself is released by the system when a plugin process crashes
+func tryLock(lockPath string) (func(), error) {
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tryLockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// lockFile wait for the lock of the given lock file until the timeout expires and returns the function releasing it
+func lockFile(lockPath string, timeout time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		unlock, err := tryLock(lockPath)
+		if err == nil {
+			return unlock, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Timeout while waiting for the lock '%s' held by another process: %s", lockPath, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// lockSiteJobsCache take the lock of the cache of the jobs of the site without waiting and returns the function releasing it
+func (d *Driver) lockSiteJobsCache() (func(), error) {
+	return tryLock(d.getSiteJobsCachePath() + ".lock")
+}
+
+// getVpnCheckCachePath returns the path of the file caching the last successful VPN check of the site for the user
+func (d *Driver) getVpnCheckCachePath() string {
+	return d.resolveDriverStorePath(fmt.Sprintf("vpn-check-%s-%s", d.G5kSite, d.G5kUsername))
+}
+
+// loadCachedVpnCheck returns the age of the last successful VPN check of the site
+func (d *Driver) loadCachedVpnCheck() (time.Duration, error) {
+	_, cacheAge, err := loadCacheFile(d.getVpnCheckCachePath())
+	return cacheAge, err
+}
+
+// storeCachedVpnCheck store the successful VPN check of the site in the driver storage directory
+func (d *Driver) storeCachedVpnCheck() error {
+	if err := storeCacheFile(d.getVpnCheckCachePath(), d.getFrontendHostname()); err != nil {
+		return fmt.Errorf("Failed to cache the VPN check: %s", err)
+	}
+
+	return nil
+}
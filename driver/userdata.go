@@ -0,0 +1,310 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+// cloudConfigList is a list that can be given either as a YAML sequence or as a comma separated string
+type cloudConfigList []string
+
+// UnmarshalYAML decode a YAML sequence or a comma separated string
+func (l *cloudConfigList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		for _, item := range strings.Split(value.Value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*l = append(*l, item)
+			}
+		}
+		return nil
+	}
+
+	var items []string
+	if err := value.Decode(&items); err != nil {
+		return err
+	}
+
+	*l = items
+	return nil
+}
+
+// cloudConfigCommand is a command given either as a string (run by the shell) or as a list of arguments
+type cloudConfigCommand struct {
+	Raw  string
+	Args []string
+}
+
+// UnmarshalYAML decode a command string or a list of arguments
+func (c *cloudConfigCommand) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&c.Raw)
+	}
+	return value.Decode(&c.Args)
+}
+
+// cloudConfigUser stores the attributes of a user to create
+type cloudConfigUser struct {
+	Name              string          `yaml:"name"`
+	Groups            cloudConfigList `yaml:"groups"`
+	Shell             string          `yaml:"shell"`
+	Sudo              string          `yaml:"sudo"`
+	SSHAuthorizedKeys []string        `yaml:"ssh_authorized_keys"`
+}
+
+// cloudConfigFile stores the attributes of a file to write
+type cloudConfigFile struct {
+	Path        string `yaml:"path"`
+	Content     string `yaml:"content"`
+	Encoding    string `yaml:"encoding"`
+	Owner       string `yaml:"owner"`
+	Permissions string `yaml:"permissions"`
+	Append      bool   `yaml:"append"`
+}
+
+// cloudConfig stores the supported subset of the cloud-config format
+type cloudConfig struct {
+	Users      []cloudConfigUser    `yaml:"users"`
+	Packages   []string             `yaml:"packages"`
+	WriteFiles []cloudConfigFile    `yaml:"write_files"`
+	RunCmd     []cloudConfigCommand `yaml:"runcmd"`
+}
+
+// ShellQuote returns the given string quoted for a POSIX shell
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// generateCloudConfigScript returns a shell script applying the given cloud-config
+func generateCloudConfigScript(userData []byte) (string, error) {
+	var config cloudConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(userData))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return "", fmt.Errorf("Invalid cloud-config: %s", err)
+	}
+
+	var script []string
+	script = append(script, "#!/bin/sh", "set -e")
+
+	for _, user := range config.Users {
+		if user.Name == "" {
+			return "", fmt.Errorf("Invalid cloud-config: a user has no name")
+		}
+
+		name := ShellQuote(user.Name)
+		script = append(script, fmt.Sprintf("echo %s", ShellQuote("Creating user "+user.Name)))
+
+		useradd := "useradd -m"
+		if user.Shell != "" {
+			useradd += " -s " + ShellQuote(user.Shell)
+		}
+		script = append(script, fmt.Sprintf("id -u %s >/dev/null 2>&1 || %s %s", name, useradd, name))
+
+		for _, group := range user.Groups {
+			script = append(script, fmt.Sprintf("getent group %s >/dev/null || groupadd %s", ShellQuote(group), ShellQuote(group)))
+			script = append(script, fmt.Sprintf("usermod -aG %s %s", ShellQuote(group), name))
+		}
+
+		if user.Sudo != "" {
+			sudoers := path.Join("/etc/sudoers.d", "90-docker-machine-"+user.Name)
+			script = append(script, fmt.Sprintf("printf '%%s\\n' %s > %s", ShellQuote(user.Name+" "+user.Sudo), ShellQuote(sudoers)))
+			script = append(script, fmt.Sprintf("chmod 440 %s", ShellQuote(sudoers)))
+		}
+
+		if len(user.SSHAuthorizedKeys) > 0 {
+			keys := base64.StdEncoding.EncodeToString([]byte(strings.Join(user.SSHAuthorizedKeys, "\n") + "\n"))
+			script = append(script, fmt.Sprintf(`home=$(getent passwd %s |cut -d: -f6) && mkdir -p "$home/.ssh"`, name))
+			script = append(script, fmt.Sprintf(`printf %s |base64 -d >> "$home/.ssh/authorized_keys"`, keys))
+			script = append(script, fmt.Sprintf(`chmod 700 "$home/.ssh" && chmod 600 "$home/.ssh/authorized_keys" && chown -R %s: "$home/.ssh"`, name))
+		}
+	}
+
+	if len(config.Packages) > 0 {
+		var packages []string
+		for _, pkg := range config.Packages {
+			packages = append(packages, ShellQuote(pkg))
+		}
+
+		script = append(script, "echo 'Installing packages'", "export DEBIAN_FRONTEND=noninteractive", "apt-get update")
+		script = append(script, fmt.Sprintf("apt-get install -y %s", strings.Join(packages, " ")))
+	}
+
+	for _, file := range config.WriteFiles {
+		if file.Path == "" {
+			return "", fmt.Errorf("Invalid cloud-config: a file has no path")
+		}
+
+		// the content is always transferred in base64 to avoid any quoting issue
+		content := file.Content
+		switch file.Encoding {
+		case "", "text/plain":
+			content = base64.StdEncoding.EncodeToString([]byte(content))
+		case "b64", "base64":
+			content = strings.Join(strings.Fields(content), "")
+		default:
+			return "", fmt.Errorf("Invalid cloud-config: the encoding '%s' of the file '%s' is not supported", file.Encoding, file.Path)
+		}
+
+		redirection := ">"
+		if file.Append {
+			redirection = ">>"
+		}
+
+		filePath := ShellQuote(file.Path)
+		script = append(script, fmt.Sprintf("echo %s", ShellQuote("Writing file "+file.Path)))
+		script = append(script, fmt.Sprintf("mkdir -p %s", ShellQuote(path.Dir(file.Path))))
+		script = append(script, fmt.Sprintf("printf %s |base64 -d %s %s", ShellQuote(content), redirection, filePath))
+		if file.Permissions != "" {
+			script = append(script, fmt.Sprintf("chmod %s %s", ShellQuote(file.Permissions), filePath))
+		}
+		if file.Owner != "" {
+			script = append(script, fmt.Sprintf("chown %s %s", ShellQuote(file.Owner), filePath))
+		}
+	}
+
+	for _, command := range config.RunCmd {
+		if command.Raw != "" {
+			script = append(script, command.Raw)
+			continue
+		}
+
+		var args []string
+		for _, arg := range command.Args {
+			args = append(args, ShellQuote(arg))
+		}
+		script = append(script, strings.Join(args, " "))
+	}
+
+	return strings.Join(script, "\n") + "\n", nil
+}
+
+// generateUserDataScript returns the script to run on the node from the user-data (a cloud-config or a shell script)
+func generateUserDataScript(userData []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(userData, []byte("#cloud-config")):
+		return generateCloudConfigScript(userData)
+	case bytes.HasPrefix(userData, []byte("#!")):
+		return string(userData), nil
+	default:
+		return "", fmt.Errorf("The user-data must be either a cloud-config (starting with '#cloud-config') or a script (starting with '#!')")
+	}
+}
+
+// checkUserData check that the user-data file is valid
+func (d *Driver) checkUserData() error {
+	userData, err := ioutil.ReadFile(d.G5kUserDataPath)
+	if err != nil {
+		return fmt.Errorf("The user-data file '%s' is not readable: %s", d.G5kUserDataPath, err)
+	}
+
+	if _, err := generateUserDataScript(userData); err != nil {
+		return fmt.Errorf("The user-data file '%s' is invalid: %s", d.G5kUserDataPath, err)
+	}
+
+	return nil
+}
+
+// g5kNodeSSHAuthTimeout is the maximum duration to wait for the driver key to be authorized on the node
+// When reusing the reference environment, the key is added to the root account by the job command once the job is running
+const g5kNodeSSHAuthTimeout time.Duration = 2 * time.Minute
+
+// dialNode opens an SSH connection to the node as root using the driver key, the connection is retried until the key is authorized
+func (d *Driver) dialNode() (*ssh.Client, error) {
+	node, err := d.GetIP()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the node hostname: %s", err.Error())
+	}
+
+	privateKey, err := ioutil.ReadFile(d.getDriverSSHKeyPath())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the driver SSH private key: %s", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the driver SSH private key: %s", err)
+	}
+
+	config := &ssh.ClientConfig{
+		User: "root",
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		// the node host key changes at each deployment
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         30 * time.Second,
+	}
+
+	deadline := time.Now().Add(g5kNodeSSHAuthTimeout)
+	for {
+		client, err := ssh.Dial("tcp", net.JoinHostPort(node, "22"), config)
+		if err == nil {
+			return client, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Failed to connect to the node '%s': %s", node, err)
+		}
+
+		log.Debugf("Failed to connect to the node '%s', retrying: %s", node, err)
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// applyUserData run the user-data on the node, its output is saved in the machine directory
+func (d *Driver) applyUserData() error {
+	if d.G5kUserDataPath == "" {
+		return nil
+	}
+
+	userData, err := ioutil.ReadFile(d.G5kUserDataPath)
+	if err != nil {
+		return fmt.Errorf("Failed to read the user-data file: %s", err)
+	}
+
+	script, err := generateUserDataScript(userData)
+	if err != nil {
+		return err
+	}
+
+	logPath := d.ResolveStorePath("user-data.log")
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to create the user-data log file: %s", err)
+	}
+	defer logFile.Close()
+
+	client, err := d.dialNode()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("Failed to open a session on the node: %s", err)
+	}
+	defer session.Close()
+
+	session.Stdin = strings.NewReader(script)
+	session.Stdout = logFile
+	session.Stderr = logFile
+
+	log.Infof("Applying user-data on the node... (output saved to '%s')", logPath)
+
+	// the script is saved to a temporary file to be run by its own interpreter
+	if err := session.Run(`f=$(mktemp) && cat > "$f" && chmod 700 "$f" && "$f"; rc=$?; rm -f "$f"; exit $rc`); err != nil {
+		return fmt.Errorf("Failed to apply the user-data on the node (see '%s' for details): %s", logPath, err)
+	}
+
+	return nil
+}
@@ -1,8 +1,12 @@
 package driver
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"net"
+	"os"
 	"strings"
 	"time"
 
@@ -72,3 +76,25 @@ func CheckSSHConnection(hostname string) error {
 	// ignore other errors because the ssh Dial will always return an error as there is no auth method configured
 	return nil
 }
+
+// FileSHA256 returns the hex encoded SHA-256 checksum of the given file
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// BytesSHA256 returns the hex encoded SHA-256 checksum of the given data
+func BytesSHA256(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
@@ -8,11 +8,12 @@ require (
 	github.com/docker/machine v0.16.2
 	github.com/go-resty/resty/v2 v2.16.2
 	golang.org/x/crypto v0.45.0
+	golang.org/x/sys v0.38.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	golang.org/x/net v0.47.0 // indirect
-	golang.org/x/sys v0.38.0 // indirect
 	golang.org/x/term v0.37.0 // indirect
 )
 
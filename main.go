@@ -1,10 +1,18 @@
 package main
 
 import (
+	"os"
+
+	"github.com/Spirals-Team/docker-machine-driver-g5k/cli"
 	"github.com/Spirals-Team/docker-machine-driver-g5k/driver"
 	"github.com/docker/machine/libmachine/drivers/plugin"
 )
 
 func main() {
+	// docker-machine starts the driver without arguments, the commands are run in standalone mode
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
 	plugin.RegisterDriver(driver.NewDriver())
 }